@@ -0,0 +1,45 @@
+package main
+
+import (
+    "fmt"
+    "testing"
+)
+
+// BenchmarkTokenHandoff measures how fast two players pass the token over
+// unbuffered channels, with no delay and no logging. An op is a round, in which
+// both players pass the token once
+func BenchmarkTokenHandoff(b *testing.B) {
+    benchmarkHandoff(b, 0)
+}
+
+// BenchmarkTokenHandoffBuffered runs the same benchmark over buffered channels of
+// a few sizes, to compare with the unbuffered baseline
+func BenchmarkTokenHandoffBuffered(b *testing.B) {
+
+    for _, buffer := range []int{1, 16, 1024} {
+
+        b.Run(fmt.Sprintf("buffer=%d", buffer), func(b *testing.B) {
+            benchmarkHandoff(b, buffer)
+        })
+    }
+}
+
+func benchmarkHandoff(b *testing.B, buffer int) {
+
+    game, err := NewGame(WithDelay(0), WithRounds(b.N), WithBuffer(buffer))
+
+    if err != nil {
+        b.Fatal(err)
+    }
+
+    b.ResetTimer()
+
+    game.Start()
+
+    for err := range game.Errors() {
+        b.Error(err)
+    }
+
+    b.StopTimer()
+    b.ReportMetric(float64(b.Elapsed().Nanoseconds()) / float64(2 * b.N), "ns/handoff")
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+    "math/rand"
+    "sync"
+    "time"
+)
+
+// Config carries the settings shared by all players. Every player gets its own
+// copy, so it must not be changed once the game started
+type Config struct {
+
+    // Delay is how long a player holds the token after receiving it
+    Delay time.Duration
+
+    // Delays gives some players, by name, their own delay instead of Delay
+    Delays map[string]time.Duration
+
+    // Jitter varies the delay at random by up to this fraction of it, between 0
+    // and 1: with 0.5 a player holds the token between half and one and a half
+    // times the delay. The random numbers come from Seed, so the same seed gives
+    // the same delays
+    Jitter float64
+    Seed int64
+
+    // jitter, when not nil, draws the jittered delays
+    jitter *jitterSource
+
+    // MaxRounds is how many times each player sends the token before stopping.
+    // Zero means no limit
+    MaxRounds int
+
+    // StallTimeout is how long a player waits for the token before giving up with
+    // ErrStalled. Zero means wait forever
+    StallTimeout time.Duration
+
+    // Buffer is the capacity of the channels between players. Zero, the default,
+    // makes them unbuffered: a send completes only when the receiver takes the
+    // token. With room in the buffer the sender moves on without waiting
+    Buffer int
+
+    // Backpressure is how long a send on a full buffer may block before the sender
+    // reports it. The sender then keeps waiting, and reports again every time the
+    // same time passes. Zero never reports
+    Backpressure time.Duration
+
+    // Deterministic makes the output of a game the same on every run, timestamps
+    // aside: the players log only while holding the token, which the unbuffered
+    // handoffs serialize, and every message starts with the number of the handoff
+    // it belongs to, so the output can also be sorted and checked for gaps. The
+    // handoffs are numbered like Token.Hops, from 1
+    Deterministic bool
+
+    // Metrics, when not nil, counts the handoffs
+    Metrics *Metrics
+
+    // Logger receives the messages of the players. Nil keeps them quiet
+    Logger Logger
+
+    // Verbose makes the players log more: when the token they got was sent, how
+    // long it took to arrive, and how long they hold it
+    Verbose bool
+
+    // gate, when not nil, holds the players back while the game is paused
+    gate *pauseGate
+
+    // events, when not nil, receives an Event for every send and receive
+    events *eventSink
+
+    // holder, when not nil, tracks which player has the token
+    holder *tokenHolder
+
+    // DropProbability is the chance, between 0 and 1, that a player drops the
+    // token instead of sending it, to simulate a lost message. The players waiting
+    // for it then stall, and report ErrTokenLost
+    DropProbability float64
+
+    // BeforeSend, when not nil, is called by the player holding the token right
+    // before it passes the token on, with the hop about to be sent. It is a hook
+    // for fault injection: a panic in it is recovered like any panic of a player
+    BeforeSend func(player string, hop int)
+
+    // lost, when not nil, remembers which player dropped the token
+    lost *lostToken
+
+    // drain, when not nil, tells the players where the token must rest once the
+    // game is drained
+    drain *drainer
+
+    // history, when not nil, records every handoff
+    history *history
+
+    // stats, when not nil, collects the PlayerStats of the players as they return
+    stats *statsCollector
+
+    // Select plays with selectPlayer instead of player: a single select that is
+    // ready both to send and to receive. It supports neither DropProbability nor
+    // Deterministic
+    Select bool
+}
+
+// delayOf returns how long the named player holds the token
+func (c Config) delayOf(name string) time.Duration {
+
+    if d, ok := c.Delays[name]; ok {
+        return d
+    }
+
+    return c.Delay
+}
+
+// logf sends a message to the logger, if there is one
+func (c Config) logf(source, format string, args ...any) {
+
+    if c.Logger != nil {
+        c.Logger.Logf(source, format, args...)
+    }
+}
+
+// jitterSource draws delays at random around a base delay. The players share it
+// and only the holder of the token draws from it, so the sequence of delays, and
+// who gets which, is the same for a given seed. It is safe for concurrent use,
+// and a nil source does not vary the delay
+type jitterSource struct {
+    mutex sync.Mutex
+    random *rand.Rand
+    fraction float64
+}
+
+func newJitterSource(fraction float64, seed int64) *jitterSource {
+    return &jitterSource{random: rand.New(rand.NewSource(seed)), fraction: fraction}
+}
+
+// delay returns base moved up or down by at most the jitter fraction of it. The
+// result is never negative
+func (j *jitterSource) delay(base time.Duration) time.Duration {
+
+    if j == nil || j.fraction == 0 || base <= 0 {
+        return base
+    }
+
+    j.mutex.Lock()
+    offset := (2 * j.random.Float64() - 1) * j.fraction
+    j.mutex.Unlock()
+
+    d := base + time.Duration(float64(base) * offset)
+
+    if d < 0 {
+        return 0
+    }
+
+    return d
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+    "testing"
+    "time"
+)
+
+func TestJitterStaysWithinBounds(t *testing.T) {
+
+    base := 100 * time.Millisecond
+    low, high := 75 * time.Millisecond, 125 * time.Millisecond
+
+    jitter := newJitterSource(0.25, 42)
+    replay := newJitterSource(0.25, 42)
+    varied := false
+
+    for i := 0; i < 1000; i++ {
+
+        d := jitter.delay(base)
+
+        if d < low || d > high {
+            t.Fatalf("delay %d is %s, outside [%s, %s]", i, d, low, high)
+        }
+
+        //
+        // the same seed draws the same delays
+        //
+        if again := replay.delay(base); again != d {
+            t.Fatalf("delay %d is %s, and %s with the same seed", i, d, again)
+        }
+
+        varied = varied || d != base
+    }
+
+    if !varied {
+        t.Fatal("the jitter never changed the delay")
+    }
+
+    if d := (*jitterSource)(nil).delay(base); d != base {
+        t.Fatalf("without jitter the delay is %s, want %s", d, base)
+    }
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+    "encoding/json"
+    "io"
+    "sync/atomic"
+    "time"
+)
+
+// Action is what a player did with the token
+type Action string
+
+const (
+    Send Action = "send"
+    Receive Action = "receive"
+)
+
+// Event describes a player sending or receiving the token, for observers of the
+// game. A player publishes Send right before the send, so the Send of a hop is
+// always published before its Receive. The select player cannot know in advance
+// which operation fires, and publishes Send right after the send instead
+type Event struct {
+    Time time.Time `json:"ts"`
+    Player string `json:"player"`
+    Action Action `json:"action"`
+    Hop int `json:"hop"`
+}
+
+// eventSink publishes events without ever blocking the players: when the buffer
+// is full because the observer falls behind, the event is dropped and counted
+type eventSink struct {
+    events chan Event
+    dropped atomic.Int64
+}
+
+func newEventSink(capacity int) *eventSink {
+    return &eventSink{events: make(chan Event, capacity)}
+}
+
+// publish queues the event, or drops it if there is no room. A nil sink ignores it
+func (s *eventSink) publish(player string, action Action, hop int) {
+
+    if s == nil {
+        return
+    }
+
+    select {
+    case s.events <- Event{Player: player, Action: action, Hop: hop, Time: time.Now()}:
+    default:
+        s.dropped.Add(1)
+    }
+}
+
+// writeJSON writes every event as a JSON object on its own line, until the events
+// channel is closed. Being the only writer, it never interleaves two lines
+func writeJSON(w io.Writer, events <-chan Event) {
+
+    encoder := json.NewEncoder(w)
+
+    for e := range events {
+        encoder.Encode(e)
+    }
+}
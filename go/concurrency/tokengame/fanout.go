@@ -0,0 +1,162 @@
+package main
+
+import (
+    "context"
+    "flag"
+    "fmt"
+    "os"
+    "os/signal"
+    "sync"
+    "time"
+)
+
+// StartFanOut shows distribution instead of strict passing: a coordinator sends
+// tasks tokens, numbered from 1, on a single channel that the workers W1, W2 ...
+// compete to receive from, so each token goes to whichever worker is free first.
+// A worker holds a token for the configured delay, as if working on it. The call
+// returns once the coordinator is done and the workers drained the channel, with
+// the number of tokens each worker got, and an error if a token was lost or
+// consumed more than once
+func StartFanOut(ctx context.Context, config Config, workers, tasks int) (map[string]int, error) {
+
+    if workers < 1 {
+        return nil, fmt.Errorf("at least one worker is needed, got %d", workers)
+    }
+
+    if tasks < 0 {
+        return nil, fmt.Errorf("the number of tasks cannot be negative, got %d", tasks)
+    }
+
+    channel := make(chan Token[int], config.Buffer)
+
+    //
+    // consumed[i] lists the tasks worker i got, it is written only by that worker
+    // and read only after the wait group is released
+    //
+    consumed := make([][]int, workers)
+
+    var waitGroup sync.WaitGroup
+
+    waitGroup.Add(1)
+
+    go func() {
+
+        defer waitGroup.Done()
+
+        //
+        // closing the channel tells the workers there are no more tasks
+        //
+        defer close(channel)
+
+        for i := 1; i <= tasks; i++ {
+
+            config.events.publish("coordinator", Send, i)
+
+            if !send(ctx, config, "coordinator", channel, Token[int]{Value: i, Hops: 1, SentAt: time.Now()}) {
+                config.logf("coordinator", "shutting down after sending %d tasks", i - 1)
+                return
+            }
+
+            config.Metrics.handoff()
+        }
+
+        config.logf("coordinator", "sent all %d tasks", tasks)
+    }()
+
+    for w := 0; w < workers; w++ {
+
+        waitGroup.Add(1)
+
+        go func(w int) {
+
+            defer waitGroup.Done()
+
+            name := fmt.Sprintf("W%d", w + 1)
+
+            for {
+
+                select {
+                case token, ok := <-channel:
+
+                    if !ok {
+                        config.logf(name, "done after %d tasks", len(consumed[w]))
+                        return
+                    }
+
+                    config.Metrics.latency(time.Since(token.SentAt))
+                    config.events.publish(name, Receive, token.Value)
+                    consumed[w] = append(consumed[w], token.Value)
+
+                    config.logf(name, "got task %d", token.Value)
+
+                    if !sleepCtx(ctx, config.Delay) {
+                        config.logf(name, "shutting down")
+                        return
+                    }
+
+                case <-ctx.Done():
+                    config.logf(name, "shutting down")
+                    return
+                }
+            }
+        }(w)
+    }
+
+    waitGroup.Wait()
+
+    counts := make(map[string]int)
+    seen := make([]int, tasks + 1)
+
+    for w, got := range consumed {
+
+        counts[fmt.Sprintf("W%d", w + 1)] = len(got)
+
+        for _, task := range got {
+            seen[task] ++
+        }
+    }
+
+    if err := ctx.Err(); err != nil {
+        return counts, err
+    }
+
+    for task := 1; task <= tasks; task++ {
+
+        if seen[task] != 1 {
+            return counts, fmt.Errorf("task %d was consumed %d times", task, seen[task])
+        }
+    }
+
+    return counts, nil
+}
+
+func runFanOut(args []string) int {
+
+    flags := flag.NewFlagSet("fanout", flag.ExitOnError)
+    delay := flags.Duration("delay", 2 * time.Second, "how long a worker holds a token, as if working on it")
+    buffer := flags.Int("buffer", 0, "the capacity of the channel the workers receive from")
+    backpressure := flags.Duration("backpressure", time.Second, "report a send blocked on a full buffer for longer than this, 0 never reports")
+    workers := flags.Int("workers", 3, "how many workers compete for the tokens")
+    tasks := flags.Int("tasks", 10, "how many tokens the coordinator fans out")
+    flags.Parse(args)
+
+    ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+    defer stop()
+
+    logger := NewLogger(os.Stdout)
+    defer logger.Close()
+
+    counts, err := StartFanOut(ctx, Config{Delay: *delay, Buffer: *buffer, Backpressure: *backpressure, Logger: logger}, *workers, *tasks)
+
+    for w := 1; w <= *workers; w++ {
+        name := fmt.Sprintf("W%d", w)
+        logger.Logf(name, "consumed %d tasks", counts[name])
+    }
+
+    if err != nil {
+        fmt.Fprintln(os.Stderr, err)
+        return 1
+    }
+
+    return 0
+}
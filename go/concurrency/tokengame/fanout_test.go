@@ -0,0 +1,29 @@
+package main
+
+import (
+    "context"
+    "testing"
+)
+
+func TestFanOutConsumesEveryTaskOnce(t *testing.T) {
+
+    for _, buffer := range []int{0, 4} {
+
+        metrics := &Metrics{}
+        counts, err := StartFanOut(context.Background(), Config{Buffer: buffer, Metrics: metrics}, 4, 100)
+
+        if err != nil {
+            t.Fatalf("buffer %d: %v", buffer, err)
+        }
+
+        total := 0
+
+        for _, count := range counts {
+            total += count
+        }
+
+        if total != 100 || metrics.Handoffs() != 100 {
+            t.Errorf("buffer %d: the workers consumed %d tasks, %d were sent, want 100", buffer, total, metrics.Handoffs())
+        }
+    }
+}
@@ -0,0 +1,637 @@
+package main
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "runtime"
+    "slices"
+    "sync"
+    "time"
+)
+
+// Game is a token passing game that can be embedded in other code: NewGame
+// configures it, Start launches the players and Stop ends the game. A game that
+// ended can be started again, and plays the same way from the beginning
+type Game struct {
+
+    config Config
+    names []string
+    starter string
+    value string
+    eventCapacity int
+    procs int
+    recordHistory bool
+
+    //
+    // the state of the current run, guarded by the mutex
+    //
+    mutex sync.Mutex
+    cancel context.CancelFunc
+    errs chan error
+    done chan struct{}
+}
+
+// Option configures a Game built by NewGame
+type Option func(*Game)
+
+// WithPlayers seats n players named "A", "B", "C" ... in the ring, two by default
+func WithPlayers(n int) Option {
+
+    return func(g *Game) {
+
+        g.names = nil
+
+        for i := 0; i < n; i++ {
+            g.names = append(g.names, playerName(i))
+        }
+    }
+}
+
+// WithPlayerNames seats the named players in the ring, in the given order
+func WithPlayerNames(names ...string) Option {
+    return func(g *Game) { g.names = names }
+}
+
+// WithStarter gives the token to the named player first. By default the first
+// player starts
+func WithStarter(name string) Option {
+    return func(g *Game) { g.starter = name }
+}
+
+// WithTokenValue sets the Value the starter seeds the token with, "." by default
+func WithTokenValue(value string) Option {
+    return func(g *Game) { g.value = value }
+}
+
+// WithProcs sets runtime.GOMAXPROCS to n while the game runs, and restores the
+// previous value once it is over. GOMAXPROCS is global to the process, so it also
+// applies to the rest of the program meanwhile. The number of threads running the
+// players changes the timing of the game, how fast the token goes around and how
+// the handoffs interleave, never its outcome: the channels order the handoffs
+// whatever the parallelism. Zero leaves GOMAXPROCS alone
+func WithProcs(n int) Option {
+    return func(g *Game) { g.procs = n }
+}
+
+// WithDelay sets how long a player holds the token, two seconds by default
+func WithDelay(d time.Duration) Option {
+    return func(g *Game) { g.config.Delay = d }
+}
+
+// WithPlayerDelay makes the named player hold the token for d, instead of the
+// delay shared by the others
+func WithPlayerDelay(name string, d time.Duration) Option {
+
+    return func(g *Game) {
+
+        if g.config.Delays == nil {
+            g.config.Delays = make(map[string]time.Duration)
+        }
+
+        g.config.Delays[name] = d
+    }
+}
+
+// WithJitter varies the delay at random by up to the given fraction of it, with
+// random numbers drawn from seed, see Config.Jitter. A zero seed picks one from
+// the clock
+func WithJitter(fraction float64, seed int64) Option {
+    return func(g *Game) { g.config.Jitter, g.config.Seed = fraction, seed }
+}
+
+// WithRounds sets how many times each player passes the token before the game
+// ends. Zero, the default, means the game runs until stopped
+func WithRounds(n int) Option {
+    return func(g *Game) { g.config.MaxRounds = n }
+}
+
+// WithStallTimeout sets how long a player waits for the token. By default it is
+// twice the time of a lap, at least a second
+func WithStallTimeout(d time.Duration) Option {
+    return func(g *Game) { g.config.StallTimeout = d }
+}
+
+// WithBuffer sets the capacity of the channels between players, zero by default
+func WithBuffer(n int) Option {
+    return func(g *Game) { g.config.Buffer = n }
+}
+
+// WithBackpressure reports sends blocked on a full buffer for longer than d, see
+// Config.Backpressure
+func WithBackpressure(d time.Duration) Option {
+    return func(g *Game) { g.config.Backpressure = d }
+}
+
+// WithDeterministic turns the deterministic mode on, see Config.Deterministic
+func WithDeterministic() Option {
+    return func(g *Game) { g.config.Deterministic = true }
+}
+
+// WithVerbose makes the players log more, see Config.Verbose
+func WithVerbose() Option {
+    return func(g *Game) { g.config.Verbose = true }
+}
+
+// WithBeforeSend installs a hook called before every send, see Config.BeforeSend
+func WithBeforeSend(hook func(player string, hop int)) Option {
+    return func(g *Game) { g.config.BeforeSend = hook }
+}
+
+// WithHistory records every handoff of the game, for History
+func WithHistory() Option {
+    return func(g *Game) { g.recordHistory = true }
+}
+
+// WithMetrics counts the handoffs in m
+func WithMetrics(m *Metrics) Option {
+    return func(g *Game) { g.config.Metrics = m }
+}
+
+// WithLogger sends the messages of the players to logger. Without it the game is
+// silent
+func WithLogger(logger Logger) Option {
+    return func(g *Game) { g.config.Logger = logger }
+}
+
+// WithDropProbability makes the players drop the token with probability p,
+// instead of passing it on, see Config.DropProbability
+func WithDropProbability(p float64) Option {
+    return func(g *Game) { g.config.DropProbability = p }
+}
+
+// WithSelectPlayers plays with the select based players, see Config.Select
+func WithSelectPlayers() Option {
+    return func(g *Game) { g.config.Select = true }
+}
+
+// WithEvents publishes an Event for every send and receive on the channel
+// returned by Events, which buffers up to capacity events
+func WithEvents(capacity int) Option {
+    return func(g *Game) { g.eventCapacity = capacity }
+}
+
+// NewGame returns a game ready to Start. Without options, two players exchange
+// the token every two seconds until the game is stopped
+func NewGame(opts ...Option) (*Game, error) {
+
+    g := &Game{
+        config: Config{Delay: 2 * time.Second, gate: newPauseGate(), holder: &tokenHolder{}},
+        names: []string{"A", "B"},
+        value: ".",
+    }
+
+    for _, opt := range opts {
+        opt(g)
+    }
+
+    if len(g.names) < 2 {
+        return nil, fmt.Errorf("at least two players are needed, got %d", len(g.names))
+    }
+
+    seen := make(map[string]bool)
+
+    for _, name := range g.names {
+
+        if name == "" {
+            return nil, errors.New("a player name cannot be empty")
+        }
+
+        if seen[name] {
+            return nil, fmt.Errorf("duplicate player name %q", name)
+        }
+
+        seen[name] = true
+    }
+
+    if g.starter == "" {
+        g.starter = g.names[0]
+    }
+
+    if !seen[g.starter] {
+        return nil, fmt.Errorf("the starter %q is not one of the players %v", g.starter, g.names)
+    }
+
+    if g.config.Buffer < 0 {
+        return nil, fmt.Errorf("the buffer capacity cannot be negative, got %d", g.config.Buffer)
+    }
+
+    if g.procs < 0 {
+        return nil, fmt.Errorf("the number of procs cannot be negative, got %d", g.procs)
+    }
+
+    if g.eventCapacity < 0 {
+        return nil, fmt.Errorf("the event capacity cannot be negative, got %d", g.eventCapacity)
+    }
+
+    if g.config.DropProbability < 0 || g.config.DropProbability > 1 {
+        return nil, fmt.Errorf("the drop probability must be between 0 and 1, got %g", g.config.DropProbability)
+    }
+
+    for name, d := range g.config.Delays {
+
+        if !seen[name] {
+            return nil, fmt.Errorf("a delay is set for %q, which is not one of the players %v", name, g.names)
+        }
+
+        if d < 0 {
+            return nil, fmt.Errorf("the delay of %s cannot be negative, got %s", name, d)
+        }
+    }
+
+    if g.config.Jitter < 0 || g.config.Jitter > 1 {
+        return nil, fmt.Errorf("the jitter must be between 0 and 1, got %g", g.config.Jitter)
+    }
+
+    if g.config.Seed == 0 {
+
+        //
+        // pick the seed once, so that a restarted game replays the same delays
+        //
+        g.config.Seed = time.Now().UnixNano()
+    }
+
+    if g.config.Select && (g.config.DropProbability > 0 || g.config.Deterministic) {
+        return nil, errors.New("the select player supports neither dropping the token nor the deterministic mode")
+    }
+
+    if g.config.StallTimeout == 0 {
+
+        //
+        // a waiting player normally gets the token back after every other player
+        // held it, so leave plenty of room above that
+        //
+        var lap time.Duration
+
+        for _, name := range g.names {
+            lap += g.config.delayOf(name)
+        }
+
+        g.config.StallTimeout = 2 * (lap + time.Duration(float64(lap) * g.config.Jitter))
+
+        if g.config.StallTimeout < time.Second {
+            g.config.StallTimeout = time.Second
+        }
+    }
+
+    return g, nil
+}
+
+// Start launches the players and returns immediately. When a player fails the
+// game cancels the others, so none of them stays blocked. Starting a game that
+// runs already does nothing, while a game that ended starts over: new channels,
+// the token back at the starter, no longer paused
+func (g *Game) Start() {
+    g.StartContext(context.Background())
+}
+
+// StartContext is like Start, and the game also ends when the parent context is
+// done, for instance when its deadline passes
+func (g *Game) StartContext(parent context.Context) {
+
+    g.mutex.Lock()
+    defer g.mutex.Unlock()
+
+    if g.running() {
+        return
+    }
+
+    ctx, cancel := context.WithCancel(parent)
+
+    g.config.holder.set(g.starter, 0)
+    g.config.gate.resume()
+    g.config.lost = &lostToken{}
+    g.config.stats = newStatsCollector()
+    g.config.drain = newDrainer()
+    g.config.history = nil
+
+    if g.recordHistory {
+        g.config.history = newHistory(g.starter)
+    }
+    g.config.jitter = nil
+
+    if g.config.Jitter > 0 {
+        g.config.jitter = newJitterSource(g.config.Jitter, g.config.Seed)
+    }
+    g.config.events = nil
+
+    if g.eventCapacity > 0 {
+        g.config.events = newEventSink(g.eventCapacity)
+    }
+
+    //
+    // the players of this run get their own copy of the configuration
+    //
+    config := g.config
+
+    //
+    // there is room for an error from every player, so a failing player never blocks
+    //
+    failures := make(chan error, len(g.names))
+    errs := make(chan error, len(g.names))
+    done := make(chan struct{})
+
+    g.cancel, g.errs, g.done = cancel, errs, done
+
+    previousProcs := 0
+
+    if g.procs > 0 {
+        previousProcs = runtime.GOMAXPROCS(g.procs)
+    }
+
+    var waitGroup sync.WaitGroup
+
+    if len(g.names) == 2 {
+        StartPingPong(ctx, &waitGroup, config, g.names, g.starter, g.value, failures)
+    } else {
+        StartRing(ctx, &waitGroup, config, g.names, g.starter, g.value, failures)
+    }
+
+    //
+    // the players are the only senders, so failures can be closed once all of them
+    // returned. It is closed last, so that nothing is left to do once done is closed
+    //
+    go func() {
+
+        waitGroup.Wait()
+
+        if config.events != nil {
+            close(config.events.events)
+        }
+
+        close(failures)
+    }()
+
+    go func() {
+
+        //
+        // done goes first, so a Start right after Errors was closed starts over
+        //
+        defer close(errs)
+        defer close(done)
+
+        for err := range failures {
+            cancel()
+            errs <- err
+        }
+
+        //
+        // a run that ended on its own releases its context too, or every restart
+        // would leave one attached to the parent
+        //
+        cancel()
+
+        if previousProcs > 0 {
+            runtime.GOMAXPROCS(previousProcs)
+        }
+    }()
+}
+
+// running tells whether the players of the current run are still active. The
+// caller holds the mutex
+func (g *Game) running() bool {
+
+    if g.done == nil {
+        return false
+    }
+
+    select {
+    case <-g.done:
+        return false
+    default:
+        return true
+    }
+}
+
+// Errors returns the channel the player errors of the current run are delivered
+// on. It is closed once all players returned, so it also tells when the run is over
+func (g *Game) Errors() <-chan error {
+
+    g.mutex.Lock()
+    defer g.mutex.Unlock()
+
+    return g.errs
+}
+
+// Done returns a channel that is closed once the current run is over: all players
+// returned, and so did the goroutines of the game. It is closed exactly once per
+// run, and is nil, so never ready, before the first Start
+func (g *Game) Done() <-chan struct{} {
+
+    g.mutex.Lock()
+    defer g.mutex.Unlock()
+
+    return g.done
+}
+
+// Events returns the channel the events of the current run are published on, nil
+// unless the game was built WithEvents. It is closed once all players returned
+func (g *Game) Events() <-chan Event {
+
+    g.mutex.Lock()
+    defer g.mutex.Unlock()
+
+    if g.config.events == nil {
+        return nil
+    }
+
+    return g.config.events.events
+}
+
+// DroppedEvents returns how many events of the current run were dropped because
+// the observer did not keep up
+func (g *Game) DroppedEvents() int64 {
+
+    g.mutex.Lock()
+    defer g.mutex.Unlock()
+
+    if g.config.events == nil {
+        return 0
+    }
+
+    return g.config.events.dropped.Load()
+}
+
+// Stats returns the statistics of the players of the current run, in seating
+// order. They are complete once the run is over, when Errors is closed
+func (g *Game) Stats() []PlayerStats {
+
+    g.mutex.Lock()
+    defer g.mutex.Unlock()
+
+    return g.config.stats.get(g.names)
+}
+
+// History returns the handoffs of the current run, in order, nil unless the game
+// was built WithHistory. Each handoff is to the player the next one is from. The
+// history is complete once the run is over
+func (g *Game) History() []Handoff {
+
+    g.mutex.Lock()
+    defer g.mutex.Unlock()
+
+    return g.config.history.get()
+}
+
+// Players returns the names of the players, in seating order
+func (g *Game) Players() []string {
+    return append([]string(nil), g.names...)
+}
+
+// CurrentHolder returns the name of the player that has the token. A token being
+// sent still belongs to the sender: the receiver becomes the holder only once it
+// got the token. Before Start there is no holder and the name is empty
+func (g *Game) CurrentHolder() string {
+    name, _ := g.config.holder.get()
+    return name
+}
+
+// Sum returns the Sum the token carried when its current holder got it. Once a
+// game limited to some rounds is over, it is the final sum of the token
+func (g *Game) Sum() int {
+    _, sum := g.config.holder.get()
+    return sum
+}
+
+// Pause freezes the game: the player holding the token keeps it until Resume, and
+// the players waiting for it do not count the pause against their stall timeout
+func (g *Game) Pause() {
+    g.config.gate.pause()
+}
+
+// Resume lets the player holding the token pass it on again
+func (g *Game) Resume() {
+    g.config.gate.resume()
+}
+
+// Drain ends the game without abandoning the token: the players pass it on until
+// the resting player gets it, and that player keeps it while all of them return.
+// Once Drain returns, CurrentHolder is the resting player, unless a player failed
+// first, which Errors tells. Stop, in contrast, cancels the players wherever the
+// token is. Draining a game that is not running does nothing
+func (g *Game) Drain(resting string) error {
+
+    if !slices.Contains(g.names, resting) {
+        return fmt.Errorf("the resting player %q is not one of the players %v", resting, g.names)
+    }
+
+    g.mutex.Lock()
+    drain, done := g.config.drain, g.done
+    g.mutex.Unlock()
+
+    if done == nil {
+        return nil
+    }
+
+    drain.request(resting)
+
+    //
+    // a paused holder would never pass the token on
+    //
+    g.Resume()
+    <-done
+
+    return nil
+}
+
+// Stop cancels the players and waits for them, and for the goroutines of the
+// game, to return. Stopping a game that is not running does nothing, and the
+// game can be started again afterwards
+func (g *Game) Stop() {
+
+    g.mutex.Lock()
+    cancel, done := g.cancel, g.done
+    g.mutex.Unlock()
+
+    if done == nil {
+        return
+    }
+
+    cancel()
+    <-done
+}
+
+// StartRing launches the named players arranged in a ring: player i receives the
+// token from player i-1 and passes it to player i+1, wrapping around, so the token
+// visits every player once per lap. Only the starter begins with the token. Two
+// players reproduce the classic back and forth exchange. Each player is added to
+// the wait group, which is released when all of them returned. A player that
+// fails sends its error on errs, which must have room for an error per player.
+// The token starts with the initial payload
+func StartRing[T any](ctx context.Context, waitGroup *sync.WaitGroup, config Config, names []string, starter string, initial T, errs chan<- error) {
+
+    n := len(names)
+
+    //
+    // channels[i] carries the token into player i
+    //
+    channels := make([]chan Token[T], n)
+
+    for i := range channels {
+        channels[i] = make(chan Token[T], config.Buffer)
+    }
+
+    for i := 0; i < n; i++ {
+
+        waitGroup.Add(1)
+
+        go func(i int) {
+            defer waitGroup.Done()
+
+            if err := play(ctx, config, names[i], i + 1, names[i] == starter, channels[i], channels[(i + 1) % n], initial); err != nil {
+                errs <- err
+            }
+        }(i)
+    }
+}
+
+// StartPingPong launches the two player game between the named players, with a
+// channel for each direction: the starter a only ever sends on aToB and receives
+// on bToA, and the other player b the other way around, so neither player can
+// read back the token it just sent. Like StartRing, whose two player ring is wired
+// the same way, it seats the players in the order of names, adds them to the
+// wait group and reports their errors on errs, which must have room for two. The
+// token starts with the initial payload
+func StartPingPong[T any](ctx context.Context, waitGroup *sync.WaitGroup, config Config, names []string, starter string, initial T, errs chan<- error) {
+
+    //
+    // a and b are indexes in names, so each player keeps its seat whoever starts
+    //
+    a, b := 0, 1
+
+    if names[1] == starter {
+        a, b = 1, 0
+    }
+
+    aToB := make(chan Token[T], config.Buffer)
+    bToA := make(chan Token[T], config.Buffer)
+
+    waitGroup.Add(2)
+
+    go func() {
+        defer waitGroup.Done()
+
+        if err := play(ctx, config, names[a], a + 1, true, bToA, aToB, initial); err != nil {
+            errs <- err
+        }
+    }()
+
+    go func() {
+        defer waitGroup.Done()
+
+        if err := play(ctx, config, names[b], b + 1, false, aToB, bToA, initial); err != nil {
+            errs <- err
+        }
+    }()
+}
+
+// playerName returns "A", "B", "C" ... for the first players in the ring and
+// "P27", "P28" ... once the alphabet runs out
+func playerName(i int) string {
+
+    if i < 26 {
+        return string(rune('A' + i))
+    }
+
+    return fmt.Sprintf("P%d", i + 1)
+}
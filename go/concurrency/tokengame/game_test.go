@@ -0,0 +1,936 @@
+package main
+
+import (
+    "bytes"
+    "context"
+    "errors"
+    "fmt"
+    "runtime"
+    "slices"
+    "strings"
+    "sync"
+    "testing"
+    "time"
+)
+
+// waitForGoroutines fails the test unless the number of goroutines goes back to
+// baseline shortly. Goroutines that returned may take a moment to be accounted for
+func waitForGoroutines(t *testing.T, baseline int) {
+
+    t.Helper()
+
+    deadline := time.Now().Add(2 * time.Second)
+
+    for runtime.NumGoroutine() > baseline {
+
+        if time.Now().After(deadline) {
+            t.Fatalf("%d goroutines are still running, %d before the game", runtime.NumGoroutine(), baseline)
+        }
+
+        time.Sleep(time.Millisecond)
+    }
+}
+
+// newTestGame builds a game from the options, failing the test on error
+func newTestGame(t *testing.T, opts ...Option) *Game {
+
+    t.Helper()
+
+    game, err := NewGame(opts...)
+
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    return game
+}
+
+// collectErrors waits for the game to end, and returns the errors of its players
+func collectErrors(game *Game) []error {
+
+    var errs []error
+
+    for err := range game.Errors() {
+        errs = append(errs, err)
+    }
+
+    return errs
+}
+
+// playRing starts a ring of n players, and returns a channel closed once all of
+// them returned. The errors of the players fail the test
+func playRing(t *testing.T, ctx context.Context, config Config, n int) <-chan struct{} {
+
+    var names []string
+
+    for i := 0; i < n; i++ {
+        names = append(names, playerName(i))
+    }
+
+    errs := make(chan error, n)
+    stopped := make(chan struct{})
+
+    var waitGroup sync.WaitGroup
+    StartRing(ctx, &waitGroup, config, names, names[0], ".", errs)
+
+    go func() {
+
+        defer close(stopped)
+
+        waitGroup.Wait()
+        close(errs)
+
+        for err := range errs {
+            t.Error(err)
+        }
+    }()
+
+    return stopped
+}
+
+func TestStopReturnsGoroutinesToBaseline(t *testing.T) {
+
+    baseline := runtime.NumGoroutine()
+
+    var output bytes.Buffer
+    logger := NewLogger(&output)
+    game := newTestGame(t, WithDelay(time.Millisecond), WithLogger(logger))
+
+    game.Start()
+    time.Sleep(20 * time.Millisecond)
+    game.Stop()
+    logger.Close()
+
+    for _, name := range []string{"A", "B"} {
+
+        if !strings.Contains(output.String(), name + ": shutting down") {
+            t.Errorf("%s did not log shutting down:\n%s", name, output.String())
+        }
+    }
+
+    waitForGoroutines(t, baseline)
+}
+
+func TestShortDelayExchangesSeveralTimes(t *testing.T) {
+
+    metrics := &Metrics{}
+    game := newTestGame(t, WithDelay(time.Millisecond), WithMetrics(metrics))
+
+    game.Start()
+    defer game.Stop()
+
+    deadline := time.Now().Add(500 * time.Millisecond)
+
+    for metrics.Handoffs() < 10 {
+
+        if time.Now().After(deadline) {
+            t.Fatalf("only %d handoffs before the deadline", metrics.Handoffs())
+        }
+
+        time.Sleep(time.Millisecond)
+    }
+}
+
+func TestRingVisitsThePlayersInOrder(t *testing.T) {
+
+    names := []string{"A", "B", "C", "D"}
+
+    var output bytes.Buffer
+    logger := NewLogger(&output)
+
+    <-playRing(t, context.Background(), Config{MaxRounds: 2, Logger: logger}, len(names))
+    logger.Close()
+
+    //
+    // the token leaves A, and every lap goes B, C, D and back to A
+    //
+    var reads []string
+
+    for _, message := range logMessages(output.String()) {
+
+        if name, text, _ := strings.Cut(message, ": "); strings.HasPrefix(text, "read the token . from the channel") {
+            reads = append(reads, name)
+        }
+    }
+
+    if len(reads) != 2 * len(names) {
+        t.Fatalf("the token was read %d times, want %d", len(reads), 2 * len(names))
+    }
+
+    for i, name := range reads {
+
+        if want := names[(i + 1) % len(names)]; name != want {
+            t.Fatalf("read %d was by %s, want %s, reads %v", i + 1, name, want, reads)
+        }
+    }
+}
+
+func TestMaxRoundsEndsTheGame(t *testing.T) {
+
+    baseline := runtime.NumGoroutine()
+
+    var output bytes.Buffer
+    logger := NewLogger(&output)
+
+    select {
+    case <-playRing(t, context.Background(), Config{MaxRounds: 3, Logger: logger}, 2):
+    case <-time.After(2 * time.Second):
+        t.Fatal("the game did not end after its rounds")
+    }
+
+    logger.Close()
+
+    for _, name := range []string{"A", "B"} {
+
+        sends, stopped := 0, false
+
+        for _, message := range logMessages(output.String()) {
+
+            if strings.HasPrefix(message, name + ": writing the token") {
+                sends ++
+            }
+
+            stopped = stopped || strings.HasPrefix(message, name + ": stopping after sending the token 3 times")
+        }
+
+        if sends != 3 || !stopped {
+            t.Errorf("%s sent the token %d times, want 3, and stopped: %v", name, sends, stopped)
+        }
+    }
+
+    waitForGoroutines(t, baseline)
+}
+
+// TestManyPlayersAreRaceFree is mostly for go test -race: eight players pass the
+// token a thousand times while the shared state is read from outside
+func TestManyPlayersAreRaceFree(t *testing.T) {
+
+    metrics := &Metrics{}
+    stopped := playRing(t, context.Background(), Config{MaxRounds: 125, Metrics: metrics}, 8)
+
+    for running := true; running; {
+
+        select {
+        case <-stopped:
+            running = false
+        default:
+            metrics.Handoffs()
+        }
+    }
+
+    if handoffs := metrics.Handoffs(); handoffs != 1000 {
+        t.Errorf("got %d handoffs, want 1000", handoffs)
+    }
+}
+
+func TestPingPongTravelsOneWayOnEachChannel(t *testing.T) {
+
+    var output bytes.Buffer
+    logger := NewLogger(&output)
+    errs := make(chan error, 2)
+
+    var waitGroup sync.WaitGroup
+
+    StartPingPong(context.Background(), &waitGroup, Config{MaxRounds: 5, Logger: logger}, []string{"A", "B"}, "A", ".", errs)
+    waitGroup.Wait()
+    close(errs)
+    logger.Close()
+
+    for err := range errs {
+        t.Error(err)
+    }
+
+    //
+    // A sends on aToB and B on bToA only, so the token goes back and forth and
+    // never comes back to the player that just sent it
+    //
+    var reads []string
+
+    for _, message := range logMessages(output.String()) {
+
+        if strings.Contains(message, ": read the token . from the channel") {
+            reads = append(reads, message)
+        }
+    }
+
+    if len(reads) != 10 {
+        t.Fatalf("the token was read %d times, want 10:\n%s", len(reads), output.String())
+    }
+
+    for i, read := range reads {
+
+        want := fmt.Sprintf("B: read the token . from the channel, hop %d", i + 1)
+
+        if i % 2 == 1 {
+            want = fmt.Sprintf("A: read the token . from the channel, hop %d", i + 1)
+        }
+
+        if read != want {
+            t.Errorf("read %d is %q, want %q", i + 1, read, want)
+        }
+    }
+}
+
+func TestDeterministicHopsHaveNoGaps(t *testing.T) {
+
+    var output bytes.Buffer
+    logger := NewLogger(&output)
+
+    game := newTestGame(t, WithDelay(0), WithRounds(10), WithDeterministic(), WithLogger(logger))
+    game.Start()
+
+    if errs := collectErrors(game); len(errs) > 0 {
+        t.Fatal(errs)
+    }
+
+    logger.Close()
+
+    //
+    // every line carries the number of its handoff, and each handoff is read once
+    //
+    var reads []int
+    last := 0
+
+    for _, message := range logMessages(output.String()) {
+
+        var name string
+        var hop int
+
+        if _, err := fmt.Sscanf(message, "%s %d", &name, &hop); err != nil {
+            t.Fatalf("%q does not start with a handoff number: %v", message, err)
+        }
+
+        if hop < last || hop > last + 1 {
+            t.Fatalf("%q jumps from handoff %d to %d", message, last, hop)
+        }
+
+        last = hop
+
+        if strings.Contains(message, "read the token") {
+            reads = append(reads, hop)
+        }
+    }
+
+    if len(reads) != 20 {
+        t.Fatalf("the token was read %d times, want 20", len(reads))
+    }
+
+    for i, hop := range reads {
+
+        if hop != i + 1 {
+            t.Fatalf("read number %d was hop %d, want %d, reads %v", i + 1, hop, i + 1, reads)
+        }
+    }
+}
+
+func TestPauseHoldsTheTokenUntilResume(t *testing.T) {
+
+    var output bytes.Buffer
+    logger := NewLogger(&output)
+    metrics := &Metrics{}
+    game := newTestGame(t, WithPlayers(3), WithDelay(time.Millisecond), WithMetrics(metrics), WithLogger(logger))
+
+    game.Start()
+    defer game.Stop()
+
+    for metrics.Handoffs() < 5 {
+        time.Sleep(time.Millisecond)
+    }
+
+    game.Pause()
+
+    //
+    // a handoff already under way when pausing still completes, then nothing moves
+    //
+    time.Sleep(20 * time.Millisecond)
+    paused := metrics.Handoffs()
+    time.Sleep(50 * time.Millisecond)
+
+    if handoffs := metrics.Handoffs(); handoffs != paused {
+        t.Fatalf("%d handoffs while paused", handoffs - paused)
+    }
+
+    game.Resume()
+
+    deadline := time.Now().Add(time.Second)
+
+    for metrics.Handoffs() < paused + 5 {
+
+        if time.Now().After(deadline) {
+            t.Fatalf("only %d handoffs after resuming", metrics.Handoffs() - paused)
+        }
+
+        time.Sleep(time.Millisecond)
+    }
+
+    game.Stop()
+    logger.Close()
+
+    //
+    // the exchange picked up where it stopped: one token, no hop skipped
+    //
+    hop := 0
+
+    for _, message := range logMessages(output.String()) {
+
+        var name string
+        var read int
+
+        if _, err := fmt.Sscanf(message, "%s read the token . from the channel, hop %d", &name, &read); err != nil {
+            continue
+        }
+
+        if hop++; read != hop {
+            t.Fatalf("read hop %d after hop %d", read, hop - 1)
+        }
+    }
+
+    if int64(hop) < paused + 5 {
+        t.Fatalf("only %d reads logged", hop)
+    }
+}
+
+func TestEventsAlternateSendAndReceive(t *testing.T) {
+
+    game := newTestGame(t, WithDelay(0), WithRounds(3), WithEvents(64))
+    game.Start()
+
+    var events []Event
+
+    for e := range game.Events() {
+        events = append(events, e)
+    }
+
+    if errs := collectErrors(game); len(errs) > 0 {
+        t.Fatal(errs)
+    }
+
+    if len(events) != 12 || game.DroppedEvents() != 0 {
+        t.Fatalf("got %d events and %d dropped, want 12 and none", len(events), game.DroppedEvents())
+    }
+
+    //
+    // A sends hop 1 and B receives it, B sends hop 2 and A receives it, and so on
+    //
+    for i, e := range events {
+
+        hop := i / 2 + 1
+        action, player := Send, "A"
+
+        if i % 2 == 1 {
+            action = Receive
+        }
+
+        if (hop % 2 == 0) != (action == Receive) {
+            player = "B"
+        }
+
+        if e.Action != action || e.Player != player || e.Hop != hop {
+            t.Errorf("event %d is %s %s hop %d, want %s %s hop %d", i, e.Player, e.Action, e.Hop, player, action, hop)
+        }
+    }
+}
+
+func TestCurrentHolderIsAlwaysAPlayer(t *testing.T) {
+
+    names := []string{"north", "east", "south", "west"}
+    game := newTestGame(t, WithPlayerNames(names...), WithDelay(0), WithRounds(200))
+
+    if holder := game.CurrentHolder(); holder != "" {
+        t.Fatalf("the holder before Start is %q", holder)
+    }
+
+    game.Start()
+
+    done := make(chan struct{})
+
+    go func() {
+        defer close(done)
+        collectErrors(game)
+    }()
+
+    seen := make(map[string]bool)
+
+    for running := true; running; {
+
+        select {
+        case <-done:
+            running = false
+        default:
+            seen[game.CurrentHolder()] = true
+        }
+    }
+
+    for holder := range seen {
+
+        if !slices.Contains(names, holder) {
+            t.Errorf("the holder %q is not a player", holder)
+        }
+    }
+}
+
+func TestTimeoutEndsTheGamePromptly(t *testing.T) {
+
+    baseline := runtime.NumGoroutine()
+
+    //
+    // with no rounds set only the timeout ends the game
+    //
+    game := newTestGame(t, WithDelay(10 * time.Millisecond))
+
+    ctx, cancel := context.WithTimeout(context.Background(), 100 * time.Millisecond)
+    defer cancel()
+
+    start := time.Now()
+    game.StartContext(ctx)
+
+    done := make(chan []error, 1)
+
+    go func() {
+        done <- collectErrors(game)
+    }()
+
+    select {
+    case errs := <-done:
+
+        if len(errs) > 0 {
+            t.Fatal(errs)
+        }
+
+    case <-time.After(time.Second):
+        game.Stop()
+        t.Fatal("the game did not end after its timeout")
+    }
+
+    if elapsed := time.Since(start); elapsed > 500 * time.Millisecond {
+        t.Errorf("the game ended %s after a 100ms timeout", elapsed)
+    }
+
+    waitForGoroutines(t, baseline)
+}
+
+func TestDroppedTokenIsReportedLost(t *testing.T) {
+
+    game := newTestGame(t, WithDelay(0), WithDropProbability(1), WithStallTimeout(50 * time.Millisecond))
+    game.Start()
+
+    errs := collectErrors(game)
+
+    if len(errs) == 0 {
+        t.Fatal("the game ended without an error")
+    }
+
+    for _, err := range errs {
+
+        if !errors.Is(err, ErrTokenLost) {
+            t.Errorf("got %v, want %v", err, ErrTokenLost)
+        }
+    }
+}
+
+func TestRestartedGamePlaysTheSameWay(t *testing.T) {
+
+    for _, starter := range []string{"A", "C"} {
+
+        logger := &recordingLogger{}
+        metrics := &Metrics{}
+        game := newTestGame(t, WithPlayers(3), WithStarter(starter), WithDelay(0), WithRounds(4), WithDeterministic(), WithMetrics(metrics), WithLogger(logger), WithHistory())
+
+        var runs [2][]string
+        var stats [2][]PlayerStats
+        var histories [2][]Handoff
+        var sums [2]int
+
+        for run := range runs {
+
+            game.Start()
+
+            if errs := collectErrors(game); len(errs) > 0 {
+                t.Fatalf("starter %s, run %d: %v", starter, run + 1, errs)
+            }
+
+            runs[run] = logger.take()
+            stats[run] = game.Stats()
+            histories[run] = game.History()
+            sums[run] = game.Sum()
+
+            if handoffs := metrics.Handoffs(); handoffs != int64(12 * (run + 1)) {
+                t.Fatalf("starter %s: %d handoffs after run %d, want %d", starter, handoffs, run + 1, 12 * (run + 1))
+            }
+        }
+
+        //
+        // the deterministic log numbers the handoffs, so the same lines mean the same
+        // handoffs, in the same order, starting from the same player
+        //
+        if len(runs[0]) == 0 || !slices.Equal(runs[0], runs[1]) {
+            t.Fatalf("starter %s: the second run logged\n%s\nthe first one\n%s", starter, strings.Join(runs[1], "\n"), strings.Join(runs[0], "\n"))
+        }
+
+        if first := runs[0][0]; !strings.HasPrefix(first, starter + ": ") {
+            t.Errorf("starter %s: the game began with %q", starter, first)
+        }
+
+        //
+        // and the same handoffs make the same history, only their times differ
+        //
+        if len(histories[0]) != 12 || len(histories[1]) != len(histories[0]) {
+            t.Fatalf("starter %s: the runs recorded %d and %d handoffs, want 12", starter, len(histories[0]), len(histories[1]))
+        }
+
+        if from := histories[0][0].From; from != starter {
+            t.Errorf("starter %s: the first handoff is from %s", starter, from)
+        }
+
+        for i, second := range histories[1] {
+
+            first := histories[0][i]
+
+            if second.From != first.From || second.To != first.To || second.Hop != first.Hop {
+                t.Errorf("starter %s: handoff %d of the second run is %+v, of the first one %+v", starter, i, second, first)
+            }
+        }
+
+        if sums[0] != 4 * 6 || sums[1] != sums[0] {
+            t.Errorf("starter %s: the runs ended with the sums %d and %d, want %d", starter, sums[0], sums[1], 4 * 6)
+        }
+
+        //
+        // the time each player held the token varies, the counts do not
+        //
+        for i, second := range stats[1] {
+
+            first := stats[0][i]
+
+            if second.Player != first.Player || second.Sent != first.Sent || second.Received != first.Received {
+                t.Errorf("starter %s: the second run counts %+v, the first one %+v", starter, second, first)
+            }
+        }
+    }
+}
+
+func TestEverySendIsReceived(t *testing.T) {
+
+    //
+    // an unbuffered handoff is both a send and a receive, so the counts match
+    // whether the game ends after its rounds or is stopped midway
+    //
+    for _, rounds := range []int{7, 0} {
+
+        game := newTestGame(t, WithPlayers(4), WithDelay(0), WithRounds(rounds))
+        game.Start()
+
+        if rounds == 0 {
+            time.Sleep(20 * time.Millisecond)
+            game.Stop()
+        }
+
+        if errs := collectErrors(game); len(errs) > 0 {
+            t.Fatal(errs)
+        }
+
+        sent, received := 0, 0
+
+        for _, stats := range game.Stats() {
+            sent += stats.Sent
+            received += stats.Received
+        }
+
+        if sent == 0 || sent != received {
+            t.Errorf("rounds %d: %d sends and %d receives", rounds, sent, received)
+        }
+    }
+}
+func TestDoneIsClosedOncePerRun(t *testing.T) {
+
+    baseline := runtime.NumGoroutine()
+    game := newTestGame(t, WithPlayers(3), WithDelay(time.Millisecond))
+
+    if game.Done() != nil {
+        t.Fatal("Done is not nil before Start")
+    }
+
+    var runs []<-chan struct{}
+
+    for run := 1; run <= 2; run++ {
+
+        game.Start()
+        done := game.Done()
+
+        select {
+        case <-done:
+            t.Fatalf("run %d: Done fired while the game runs", run)
+        case <-time.After(20 * time.Millisecond):
+        }
+
+        game.Stop()
+
+        select {
+        case <-done:
+        case <-time.After(time.Second):
+            t.Fatalf("run %d: Done did not fire once the game stopped", run)
+        }
+
+        waitForGoroutines(t, baseline)
+        runs = append(runs, done)
+    }
+
+    if runs[0] == runs[1] {
+        t.Fatal("both runs share the same Done channel")
+    }
+}
+
+func TestFinalSumCountsEverySeat(t *testing.T) {
+
+    for _, n := range []int{2, 3, 7} {
+
+        rounds := 5
+        game := newTestGame(t, WithPlayers(n), WithDelay(0), WithRounds(rounds))
+        game.Start()
+
+        if errs := collectErrors(game); len(errs) > 0 {
+            t.Fatal(errs)
+        }
+
+        if sum, want := game.Sum(), rounds * n * (n + 1) / 2; sum != want {
+            t.Errorf("%d players: the final sum is %d, want %d", n, sum, want)
+        }
+    }
+}
+
+func TestSeatsFollowThePlayerNames(t *testing.T) {
+
+    //
+    // the seat of a player is its place in the names, whoever starts
+    //
+    for _, names := range [][]string{{"A", "B"}, {"A", "B", "C"}} {
+
+        for _, starter := range names {
+
+            logger := &recordingLogger{}
+            game := newTestGame(t, WithPlayerNames(names...), WithStarter(starter), WithDelay(0), WithRounds(2), WithLogger(logger))
+            game.Start()
+
+            if errs := collectErrors(game); len(errs) > 0 {
+                t.Fatal(errs)
+            }
+
+            added := 0
+
+            for _, message := range logger.take() {
+
+                var name string
+                var seat, sum int
+
+                if _, err := fmt.Sscanf(message, "%s added %d, the sum is now %d", &name, &seat, &sum); err != nil {
+                    continue
+                }
+
+                if want := slices.Index(names, strings.TrimSuffix(name, ":")) + 1; seat != want {
+                    t.Errorf("%v started by %s: %s added %d, want its seat %d", names, starter, name, seat, want)
+                }
+
+                added ++
+            }
+
+            if added != 2 * len(names) {
+                t.Errorf("%v started by %s: %d seats added, want %d", names, starter, added, 2 * len(names))
+            }
+        }
+    }
+}
+func TestTokenValueShowsInTheLog(t *testing.T) {
+
+    var output bytes.Buffer
+    logger := NewLogger(&output)
+
+    game := newTestGame(t, WithPlayers(3), WithDelay(0), WithRounds(2), WithTokenValue("game-7"), WithLogger(logger))
+    game.Start()
+
+    if errs := collectErrors(game); len(errs) > 0 {
+        t.Fatal(errs)
+    }
+
+    logger.Close()
+
+    for _, name := range []string{"A", "B", "C"} {
+
+        if count := strings.Count(output.String(), name + ": read the token game-7 from the channel"); count != 2 {
+            t.Errorf("%s read the token game-7 %d times, want 2:\n%s", name, count, output.String())
+        }
+    }
+}
+func TestPanicEndsTheGameWithErrPanicked(t *testing.T) {
+
+    baseline := runtime.NumGoroutine()
+
+    game := newTestGame(t, WithDelay(0), WithBeforeSend(func(player string, hop int) {
+
+        if hop == 5 {
+            panic("boom")
+        }
+    }))
+
+    game.Start()
+
+    select {
+    case <-game.Done():
+    case <-time.After(2 * time.Second):
+        t.Fatal("the game did not end after the panic")
+    }
+
+    errs := collectErrors(game)
+
+    //
+    // the peer is cancelled, and returns without an error of its own
+    //
+    if len(errs) != 1 || !errors.Is(errs[0], ErrPanicked) {
+        t.Fatalf("got %v, want a single %v", errs, ErrPanicked)
+    }
+
+    waitForGoroutines(t, baseline)
+}
+
+func TestPlayerDelayIsHowLongThePlayerHolds(t *testing.T) {
+
+    slow := 30 * time.Millisecond
+    game := newTestGame(t, WithPlayers(3), WithDelay(time.Millisecond), WithPlayerDelay("B", slow), WithRounds(4))
+    game.Start()
+
+    if errs := collectErrors(game); len(errs) > 0 {
+        t.Fatal(errs)
+    }
+
+    //
+    // a player holds the token from the handoff to it until the handoff from it,
+    // the delay included
+    //
+    for _, stats := range game.Stats() {
+
+        if stats.Received == 0 {
+            t.Fatalf("%s never got the token", stats.Player)
+        }
+
+        held := stats.Active / time.Duration(stats.Received)
+
+        if stats.Player == "B" && held < slow {
+            t.Errorf("B held the token for %s on average, want at least %s", held, slow)
+        }
+
+        if stats.Player != "B" && held >= slow / 2 {
+            t.Errorf("%s held the token for %s on average, want about 1ms", stats.Player, held)
+        }
+    }
+}
+func TestDrainRestsTheTokenWhereStopDoesNot(t *testing.T) {
+
+    names := []string{"A", "B", "C", "D"}
+
+    for _, resting := range names {
+
+        game := newTestGame(t, WithPlayerNames(names...), WithDelay(time.Millisecond))
+        game.Start()
+        time.Sleep(10 * time.Millisecond)
+
+        if err := game.Drain(resting); err != nil {
+            t.Fatal(err)
+        }
+
+        if errs := collectErrors(game); len(errs) > 0 {
+            t.Fatal(errs)
+        }
+
+        if holder := game.CurrentHolder(); holder != resting {
+            t.Errorf("drained to %s, the token rests with %s", resting, holder)
+        }
+    }
+
+    //
+    // Stop leaves the token wherever it was, so over a few stops it ends with
+    // several players
+    //
+    stopped := make(map[string]bool)
+
+    for i := 0; i < 20 && len(stopped) < 2; i++ {
+
+        game := newTestGame(t, WithPlayerNames(names...), WithDelay(time.Millisecond))
+        game.Start()
+        time.Sleep(time.Duration(i % 7 + 1) * time.Millisecond)
+        game.Stop()
+
+        stopped[game.CurrentHolder()] = true
+    }
+
+    if len(stopped) < 2 {
+        t.Errorf("every stop left the token with %v", stopped)
+    }
+
+    if err := newTestGame(t).Drain("Z"); err == nil {
+        t.Error("draining to a player that is not in the game did not fail")
+    }
+}
+
+func TestProcsAreRestoredAfterTheGame(t *testing.T) {
+
+    previous := runtime.GOMAXPROCS(0)
+    procs := 1
+
+    if previous == 1 {
+        procs = 2
+    }
+
+    var during int
+    game := newTestGame(t, WithPlayers(3), WithDelay(0), WithRounds(50), WithProcs(procs), WithBeforeSend(func(player string, hop int) {
+
+        if hop == 1 {
+            during = runtime.GOMAXPROCS(0)
+        }
+    }))
+
+    game.Start()
+    <-game.Done()
+
+    if errs := collectErrors(game); len(errs) > 0 {
+        t.Fatal(errs)
+    }
+
+    if during != procs {
+        t.Errorf("the game ran with GOMAXPROCS %d, want %d", during, procs)
+    }
+
+    if sum := game.Sum(); sum != 50 * 6 {
+        t.Errorf("the final sum is %d, want %d", sum, 50 * 6)
+    }
+
+    if now := runtime.GOMAXPROCS(0); now != previous {
+        t.Fatalf("GOMAXPROCS is %d after the game, want %d back", now, previous)
+    }
+}
+
+func TestHistoryChainsTheHandoffs(t *testing.T) {
+
+    metrics := &Metrics{}
+    game := newTestGame(t, WithPlayers(5), WithStarter("D"), WithDelay(0), WithRounds(6), WithMetrics(metrics), WithHistory())
+    game.Start()
+
+    if errs := collectErrors(game); len(errs) > 0 {
+        t.Fatal(errs)
+    }
+
+    history := game.History()
+
+    if int64(len(history)) != metrics.Handoffs() {
+        t.Fatalf("the history has %d handoffs, the metrics count %d", len(history), metrics.Handoffs())
+    }
+
+    if history[0].From != "D" {
+        t.Errorf("the first handoff is from %s, want the starter D", history[0].From)
+    }
+
+    for i := 1; i < len(history); i++ {
+
+        if history[i].From != history[i - 1].To {
+            t.Errorf("handoff %d is to %s, and the next one from %s", i - 1, history[i - 1].To, history[i].From)
+        }
+    }
+}
@@ -0,0 +1,3 @@
+module tokengame
+
+go 1.22
@@ -0,0 +1,51 @@
+package main
+
+import (
+    "encoding/json"
+    "fmt"
+    "net/http"
+)
+
+// status is what /status answers
+type status struct {
+    Running bool `json:"running"`
+    Holder string `json:"holder"`
+    Exchanges int64 `json:"exchanges"`
+}
+
+// statusHandler serves /status, the holder of the token and how many times it was
+// exchanged, as JSON, and /stop, which ends the game. The handlers only read state
+// the game guards itself, so they can run while the players do
+func statusHandler(game *Game, metrics *Metrics) http.Handler {
+
+    mux := http.NewServeMux()
+
+    mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+
+        done := game.Done()
+        running := done != nil
+
+        select {
+        case <-done:
+            running = false
+        default:
+        }
+
+        w.Header().Set("Content-Type", "application/json")
+        json.NewEncoder(w).Encode(status{Running: running, Holder: game.CurrentHolder(), Exchanges: metrics.Handoffs()})
+    })
+
+    mux.HandleFunc("/stop", func(w http.ResponseWriter, r *http.Request) {
+
+        if r.Method != http.MethodPost {
+            w.Header().Set("Allow", http.MethodPost)
+            http.Error(w, "use POST to stop the game", http.StatusMethodNotAllowed)
+            return
+        }
+
+        game.Stop()
+        fmt.Fprintln(w, "stopped")
+    })
+
+    return mux
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+    "encoding/json"
+    "net/http"
+    "net/http/httptest"
+    "testing"
+    "time"
+)
+
+// getStatus fetches /status from the server
+func getStatus(t *testing.T, server *httptest.Server) status {
+
+    t.Helper()
+
+    response, err := http.Get(server.URL + "/status")
+
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    defer response.Body.Close()
+
+    if contentType := response.Header.Get("Content-Type"); contentType != "application/json" {
+        t.Fatalf("/status is served as %q", contentType)
+    }
+
+    var s status
+
+    if err := json.NewDecoder(response.Body).Decode(&s); err != nil {
+        t.Fatal(err)
+    }
+
+    return s
+}
+
+func TestStatusReportsTheHolderAndExchanges(t *testing.T) {
+
+    metrics := &Metrics{}
+    game := newTestGame(t, WithDelay(time.Millisecond), WithMetrics(metrics))
+
+    server := httptest.NewServer(statusHandler(game, metrics))
+    defer server.Close()
+
+    game.Start()
+    defer game.Stop()
+
+    for metrics.Handoffs() < 5 {
+        time.Sleep(time.Millisecond)
+    }
+
+    //
+    // nothing moves while the game is paused, so the answer can be checked
+    //
+    game.Pause()
+    time.Sleep(20 * time.Millisecond)
+
+    s := getStatus(t, server)
+
+    if !s.Running || s.Holder != game.CurrentHolder() || s.Exchanges != metrics.Handoffs() {
+        t.Fatalf("/status answered %+v, want running, holder %s and %d exchanges", s, game.CurrentHolder(), metrics.Handoffs())
+    }
+
+    response, err := http.Post(server.URL + "/stop", "", nil)
+
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    response.Body.Close()
+
+    if s := getStatus(t, server); s.Running {
+        t.Fatalf("/status answered %+v after /stop", s)
+    }
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+    "context"
+    "flag"
+    "fmt"
+    "os"
+    "os/signal"
+    "sync"
+    "sync/atomic"
+    "time"
+)
+
+// StartLeaderElection uses the token for mutual exclusion: n players all wait to
+// receive it from one shared channel, and whoever gets it is the leader until it
+// puts it back, after working for the configured delay. The token is handed out
+// rounds times in all, then the last leader closes the channel instead of putting
+// it back, which ends the game. The call returns how many times each player led,
+// and an error if two players ever held the token at once
+func StartLeaderElection(ctx context.Context, config Config, n, rounds int) (map[string]int, error) {
+
+    if n < 1 {
+        return nil, fmt.Errorf("at least one player is needed, got %d", n)
+    }
+
+    if rounds < 1 {
+        return nil, fmt.Errorf("at least one round is needed, got %d", rounds)
+    }
+
+    //
+    // the token waits in the buffer while it has no leader, so putting it back
+    // never blocks
+    //
+    shared := make(chan Token[int], 1)
+    shared <- Token[int]{SentAt: time.Now()}
+
+    //
+    // leaders counts the players holding the token, and most the highest count seen
+    //
+    var leaders, most atomic.Int64
+
+    terms := make([]int, n)
+
+    var waitGroup sync.WaitGroup
+
+    for i := 0; i < n; i++ {
+
+        waitGroup.Add(1)
+
+        go func(i int) {
+
+            defer waitGroup.Done()
+
+            name := playerName(i)
+
+            for {
+
+                var token Token[int]
+                var ok bool
+
+                select {
+                case token, ok = <-shared:
+                case <-ctx.Done():
+                    config.logf(name, "shutting down")
+                    return
+                }
+
+                if !ok {
+                    config.logf(name, "done after leading %d times", terms[i])
+                    return
+                }
+
+                holding := leaders.Add(1)
+
+                for seen := most.Load(); holding > seen && !most.CompareAndSwap(seen, holding); {
+                    seen = most.Load()
+                }
+
+                config.Metrics.latency(time.Since(token.SentAt))
+                token.Hops ++
+                terms[i] ++
+                config.logf(name, "leading, term %d", token.Hops)
+
+                working := sleepCtx(ctx, config.Delay)
+
+                leaders.Add(-1)
+
+                if !working {
+                    config.logf(name, "shutting down")
+                    return
+                }
+
+                if token.Hops == rounds {
+                    config.logf(name, "done after leading %d times, closing the election", terms[i])
+                    close(shared)
+                    return
+                }
+
+                token.SentAt = time.Now()
+                shared <- token
+                config.Metrics.handoff()
+            }
+        }(i)
+    }
+
+    waitGroup.Wait()
+
+    counts := make(map[string]int)
+
+    for i, led := range terms {
+        counts[playerName(i)] = led
+    }
+
+    if held := most.Load(); held > 1 {
+        return counts, fmt.Errorf("%d players held the token at once", held)
+    }
+
+    if err := ctx.Err(); err != nil {
+        return counts, err
+    }
+
+    return counts, nil
+}
+
+func runLeaderElection(args []string) int {
+
+    flags := flag.NewFlagSet("leader", flag.ExitOnError)
+    delay := flags.Duration("delay", 200 * time.Millisecond, "how long a leader works before putting the token back")
+    n := flags.Int("n", 4, "the number of players competing for the token")
+    rounds := flags.Int("rounds", 10, "how many times the token is handed out in all")
+    flags.Parse(args)
+
+    ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+    defer stop()
+
+    logger := NewLogger(os.Stdout)
+    defer logger.Close()
+
+    counts, err := StartLeaderElection(ctx, Config{Delay: *delay, Logger: logger}, *n, *rounds)
+
+    for i := 0; i < *n; i++ {
+        name := playerName(i)
+        logger.Logf(name, "led %d times", counts[name])
+    }
+
+    if err != nil {
+        fmt.Fprintln(os.Stderr, err)
+        return 1
+    }
+
+    return 0
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+    "context"
+    "testing"
+)
+
+func TestLeaderElectionHasOneLeaderAtATime(t *testing.T) {
+
+    //
+    // the election fails as soon as two players held the token at once
+    //
+    counts, err := StartLeaderElection(context.Background(), Config{}, 8, 2000)
+
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    terms := 0
+
+    for _, led := range counts {
+        terms += led
+    }
+
+    if len(counts) != 8 || terms != 2000 {
+        t.Fatalf("%d players led %d terms in all, want 8 and 2000: %v", len(counts), terms, counts)
+    }
+}
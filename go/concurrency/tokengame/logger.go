@@ -0,0 +1,60 @@
+package main
+
+import (
+    "fmt"
+    "io"
+    "time"
+)
+
+// Logger receives the messages of the game, each call being one line. The source
+// is the player, or the part of the game, the message comes from
+type Logger interface {
+    Logf(source, format string, args ...any)
+}
+
+// LineLogger writes every message as a "HH:MM:SS.mmm SOURCE: message" line, the
+// time being when Logf was called. All lines are written by a single goroutine,
+// in the order Logf was called, so concurrent players never split each other's
+// lines. Close must be called, once nobody logs anymore, to flush the last lines
+type LineLogger struct {
+    entries chan logEntry
+    done chan struct{}
+}
+
+type logEntry struct {
+    time time.Time
+    source string
+    message string
+}
+
+// NewLogger returns a LineLogger writing to w, with its writing goroutine started
+func NewLogger(w io.Writer) *LineLogger {
+
+    l := &LineLogger{
+        entries: make(chan logEntry, 128),
+        done: make(chan struct{}),
+    }
+
+    go func() {
+
+        defer close(l.done)
+
+        for entry := range l.entries {
+            fmt.Fprintf(w, "%s %s: %s\n", entry.time.Format("15:04:05.000"), entry.source, entry.message)
+        }
+    }()
+
+    return l
+}
+
+// Logf queues the message for the writing goroutine. It blocks while the queue
+// is full, so no message is ever lost
+func (l *LineLogger) Logf(source, format string, args ...any) {
+    l.entries <- logEntry{time: time.Now(), source: source, message: fmt.Sprintf(format, args...)}
+}
+
+// Close writes the queued messages and stops the writing goroutine
+func (l *LineLogger) Close() {
+    close(l.entries)
+    <-l.done
+}
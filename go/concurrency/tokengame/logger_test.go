@@ -0,0 +1,88 @@
+package main
+
+import (
+    "bytes"
+    "fmt"
+    "regexp"
+    "strings"
+    "sync"
+    "testing"
+)
+
+// logMessages returns every line logged to output without its time, as
+// "SOURCE: message"
+func logMessages(output string) []string {
+
+    var messages []string
+
+    for _, line := range strings.Split(strings.TrimSuffix(output, "\n"), "\n") {
+
+        if _, message, ok := strings.Cut(line, " "); ok {
+            messages = append(messages, message)
+        }
+    }
+
+    return messages
+}
+
+func TestLineLoggerFormat(t *testing.T) {
+
+    var output bytes.Buffer
+    logger := NewLogger(&output)
+
+    logger.Logf("A", "read the token %s from the channel, hop %d", ".", 1)
+    logger.Logf("B", "shutting down")
+    logger.Close()
+
+    line := regexp.MustCompile(`^\d{2}:\d{2}:\d{2}\.\d{3} (\S+): (.+)$`)
+    lines := strings.Split(strings.TrimSuffix(output.String(), "\n"), "\n")
+
+    want := [][2]string{
+        {"A", "read the token . from the channel, hop 1"},
+        {"B", "shutting down"},
+    }
+
+    if len(lines) != len(want) {
+        t.Fatalf("got %d lines, want %d:\n%s", len(lines), len(want), output.String())
+    }
+
+    for i, text := range lines {
+
+        match := line.FindStringSubmatch(text)
+
+        if match == nil {
+            t.Errorf("line %q is not HH:MM:SS.mmm PLAYER: message", text)
+            continue
+        }
+
+        if match[1] != want[i][0] || match[2] != want[i][1] {
+            t.Errorf("line %d is %s: %s, want %s: %s", i + 1, match[1], match[2], want[i][0], want[i][1])
+        }
+    }
+}
+
+// recordingLogger keeps the messages logged to it, as "SOURCE: message"
+type recordingLogger struct {
+    mutex sync.Mutex
+    messages []string
+}
+
+func (l *recordingLogger) Logf(source, format string, args ...any) {
+
+    l.mutex.Lock()
+    defer l.mutex.Unlock()
+
+    l.messages = append(l.messages, source + ": " + fmt.Sprintf(format, args...))
+}
+
+// take returns the messages logged so far, and forgets them
+func (l *recordingLogger) take() []string {
+
+    l.mutex.Lock()
+    defer l.mutex.Unlock()
+
+    messages := l.messages
+    l.messages = nil
+
+    return messages
+}
@@ -0,0 +1,556 @@
+package main
+
+import (
+    "bufio"
+    "context"
+    "errors"
+    "flag"
+    "fmt"
+    "io"
+    "net/http"
+    "os"
+    "os/signal"
+    "runtime"
+    "slices"
+    "strings"
+    "time"
+)
+
+//
+// A token passing game played by goroutines over unbuffered channels, or over
+// buffered ones with -buffer, for comparison. The pingpong, ring and fanout
+// commands run the different topologies, twotoken a deadlock and its avoidance,
+// leader mutual exclusion by a token, and priority shows a select preferring one
+// channel over another. go test -bench . measures the handoff.
+//
+// The game itself is in game.go, played by the players of player.go and
+// select-player.go, and every other demo has a file of its own.
+//
+// The players share no memory: the Token is copied on every send, the send
+// counters are local to each player and the end of the game travels as closed
+// channels, each closed only by its single sender. The only state touched by
+// more than one goroutine, Metrics and the unexported trackers of Config, is
+// guarded by atomics and mutexes, so the game is clean under the race detector:
+// go run -race . ring -delay 0 -n 8 -rounds 200
+//
+
+// command is one of the demos runnable from the command line
+type command struct {
+    name string
+    description string
+
+    // run gets the arguments following the command name, and returns the exit code
+    run func(args []string) int
+}
+
+var commands = []command{
+    {"pingpong", "two players pass the token back and forth, the default", runPingPong},
+    {"ring", "players pass the token around a ring", runRing},
+    {"fanout", "a coordinator fans tokens out to competing workers", runFanOut},
+    {"twotoken", "two tokens go around a ring in opposite directions, and deadlock unless -safe", runTwoToken},
+    {"leader", "players compete for the token on a shared channel, the one getting it leads", runLeaderElection},
+    {"priority", "a priority token overtakes the normal tokens waiting on another channel", runPriority},
+}
+
+func main() {
+
+    args := os.Args[1:]
+
+    //
+    // without a command, flags alone included, play ping pong
+    //
+    name := "pingpong"
+
+    if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+        name, args = args[0], args[1:]
+    }
+
+    for _, c := range commands {
+
+        if c.name == name {
+            os.Exit(c.run(args))
+        }
+    }
+
+    fmt.Fprintf(os.Stderr, "unknown command %q\n\n", name)
+    usage()
+    os.Exit(2)
+}
+
+func usage() {
+
+    fmt.Fprintf(os.Stderr, "usage: %s <command> [flags]\n\ncommands:\n", os.Args[0])
+
+    for _, c := range commands {
+        fmt.Fprintf(os.Stderr, "  %-10s %s\n", c.name, c.description)
+    }
+
+    fmt.Fprintf(os.Stderr, "\n%s <command> -h lists the flags of a command\n", os.Args[0])
+}
+
+// gameFlags are the flags shared by the commands playing a Game
+type gameFlags struct {
+    delay *time.Duration
+    stall *time.Duration
+    buffer *int
+    players *string
+    starter *string
+    rounds *int
+    selectPlayers *bool
+    deterministic *bool
+    drop *float64
+    timeout *time.Duration
+    interval *time.Duration
+    jitter *float64
+    seed *int64
+    quiet *bool
+    verbose *bool
+    backpressure *time.Duration
+    http *string
+    token *string
+    panicAt *int
+    visualize *bool
+    delays map[string]time.Duration
+    drain *string
+    json *bool
+    procs *int
+    history *bool
+    pausable *bool
+}
+
+func addGameFlags(flags *flag.FlagSet) *gameFlags {
+
+    f := &gameFlags{
+        delay: flags.Duration("delay", 2 * time.Second, "how long a player holds the token before passing it on"),
+        stall: flags.Duration("stall", 0, "how long a player waits for the token before giving up, 0 picks twice the time of a lap, at least a second"),
+        buffer: flags.Int("buffer", 0, "the capacity of the channels between players, 0 makes them unbuffered"),
+        players: flags.String("players", "", "comma separated player names, in ring order"),
+        starter: flags.String("starter", "", "the name of the player that starts with the token, the first one by default"),
+        rounds: flags.Int("rounds", 0, "how many times each player passes the token, 0 means never stop"),
+        selectPlayers: flags.Bool("select", false, "use players built around a single select, ready to send and to receive at once"),
+        deterministic: flags.Bool("deterministic", false, "log only the token holder, with handoff numbers, so every run prints the same lines"),
+        drop: flags.Float64("drop", 0, "the probability, between 0 and 1, that a player drops the token instead of passing it on"),
+        timeout: flags.Duration("timeout", 0, "end the game after this long, whatever the rounds, 0 means no deadline"),
+        interval: flags.Duration("metrics", time.Second, "how often the throughput is reported, 0 turns the report off"),
+        jitter: flags.Float64("jitter", 0, "vary the delay at random by up to this fraction of it, between 0 and 1"),
+        seed: flags.Int64("seed", 0, "the seed of the jitter, the same seed gives the same delays, 0 picks one from the clock"),
+        backpressure: flags.Duration("backpressure", time.Second, "report a send blocked on a full buffer for longer than this, 0 never reports"),
+        token: flags.String("token", ".", "the value the starter seeds the token with, to tell games apart in combined output"),
+        panicAt: flags.Int("panic", 0, "make the player about to send this hop panic, to see the game survive it, 0 never panics"),
+        visualize: flags.Bool("visualize", false, "draw the ring and where the token is on a single line, instead of logging"),
+        drain: flags.String("drain", "", "on interrupt, drain the game so the token rests with this player, instead of stopping at once. A second interrupt stops at once"),
+        json: flags.Bool("json", false, "print every event as a JSON object on its own line instead of logging, the summary goes to the standard error"),
+        procs: flags.Int("procs", 0, "run the game with GOMAXPROCS set to this, which changes its timing but not its outcome, 0 keeps the default"),
+        history: flags.Bool("history", false, "print every handoff, from which player to which, at the end of the game"),
+        http: flags.String("http", "", "serve /status and /stop on this address, :8080 for instance, while the game runs"),
+        quiet: flags.Bool("quiet", false, "print only the summary at the end of the game"),
+        verbose: flags.Bool("verbose", false, "also log when every token was sent, how long it took to arrive and how long it is held"),
+        delays: make(map[string]time.Duration),
+        pausable: flags.Bool("pausable", false, "pause and resume the game with every line read on stdin, Enter alone is enough"),
+    }
+
+    flags.Func("delays", "comma separated delays of the players that do not use -delay, A=100ms,B=300ms for instance", f.parseDelays)
+
+    return f
+}
+
+// parseDelays reads the -delays flag, name=duration pairs separated by commas
+func (f *gameFlags) parseDelays(value string) error {
+
+    for _, pair := range strings.Split(value, ",") {
+
+        name, delay, ok := strings.Cut(strings.TrimSpace(pair), "=")
+
+        if !ok || name == "" {
+            return fmt.Errorf("%q is not of the form name=duration", pair)
+        }
+
+        d, err := time.ParseDuration(delay)
+
+        if err != nil {
+            return err
+        }
+
+        f.delays[name] = d
+    }
+
+    return nil
+}
+
+// names returns the player names given with -players, nil if there are none
+func (f *gameFlags) names() []string {
+
+    if *f.players == "" {
+        return nil
+    }
+
+    names := strings.Split(*f.players, ",")
+
+    for i := range names {
+        names[i] = strings.TrimSpace(names[i])
+    }
+
+    return names
+}
+
+func (f *gameFlags) options() []Option {
+
+    opts := []Option{
+        WithStarter(*f.starter),
+        WithDelay(*f.delay),
+        WithRounds(*f.rounds),
+        WithStallTimeout(*f.stall),
+        WithBuffer(*f.buffer),
+        WithDropProbability(*f.drop),
+        WithJitter(*f.jitter, *f.seed),
+        WithBackpressure(*f.backpressure),
+        WithTokenValue(*f.token),
+        WithProcs(*f.procs),
+    }
+
+    if *f.history {
+        opts = append(opts, WithHistory())
+    }
+
+    if *f.selectPlayers {
+        opts = append(opts, WithSelectPlayers())
+    }
+
+    if *f.deterministic {
+        opts = append(opts, WithDeterministic())
+    }
+
+    if *f.verbose {
+        opts = append(opts, WithVerbose())
+    }
+
+    for name, d := range f.delays {
+        opts = append(opts, WithPlayerDelay(name, d))
+    }
+
+    if hop := *f.panicAt; hop > 0 {
+
+        opts = append(opts, WithBeforeSend(func(player string, sending int) {
+
+            if sending == hop {
+                panic(fmt.Sprintf("injected panic at hop %d", hop))
+            }
+        }))
+    }
+
+    return opts
+}
+
+func runPingPong(args []string) int {
+
+    flags := flag.NewFlagSet("pingpong", flag.ExitOnError)
+    f := addGameFlags(flags)
+    flags.Parse(args)
+
+    names := f.names()
+
+    if names == nil {
+        names = []string{"A", "B"}
+    }
+
+    if len(names) != 2 {
+        fmt.Fprintf(os.Stderr, "ping pong takes exactly two players, got %d, the ring command takes more\n", len(names))
+        return 1
+    }
+
+    return playGame(f, append(f.options(), WithPlayerNames(names...)), os.Stdout, os.Stderr)
+}
+
+func runRing(args []string) int {
+
+    flags := flag.NewFlagSet("ring", flag.ExitOnError)
+    f := addGameFlags(flags)
+    n := flags.Int("n", 3, "the number of players in the ring, named A, B, C ..., unless -players names them")
+    flags.Parse(args)
+
+    opts := append(f.options(), WithPlayers(*n))
+
+    if names := f.names(); names != nil {
+        opts = append(opts, WithPlayerNames(names...))
+    }
+
+    return playGame(f, opts, os.Stdout, os.Stderr)
+}
+
+// playGame plays a game with the given options until it ends, on its own, on
+// Ctrl-C or at the -timeout deadline, and returns the exit code. The game writes
+// its output on stdout and its errors on stderr
+func playGame(f *gameFlags, opts []Option, stdout, stderr io.Writer) int {
+
+    if *f.quiet && *f.verbose {
+        fmt.Fprintln(stderr, "-quiet and -verbose cannot be combined")
+        return 2
+    }
+
+    if *f.visualize && (*f.quiet || *f.verbose || *f.json) {
+        fmt.Fprintln(stderr, "-visualize replaces the log, it cannot be combined with -quiet, -verbose or -json")
+        return 2
+    }
+
+    if *f.json && *f.verbose {
+        fmt.Fprintln(stderr, "-json replaces the log, it cannot be combined with -verbose")
+        return 2
+    }
+
+    //
+    // in visualize and JSON modes the standard output only gets the frames of the
+    // ring or the events, so the rest of the game reports on the standard error
+    //
+    output := stdout
+
+    if *f.visualize || *f.json {
+        output = stderr
+    }
+
+    logger := NewLogger(output)
+    defer logger.Close()
+
+    metrics := &Metrics{}
+    opts = append(opts, WithMetrics(metrics))
+
+    //
+    // in quiet mode the players get no logger, and only the summary is printed. In
+    // visualize and JSON modes the events replace their log
+    //
+    if !*f.quiet && !*f.visualize && !*f.json {
+        opts = append(opts, WithLogger(logger))
+    }
+
+    if *f.visualize {
+        opts = append(opts, WithEvents(64))
+    }
+
+    if *f.json {
+        opts = append(opts, WithEvents(1024))
+    }
+
+    game, err := NewGame(opts...)
+
+    if err != nil {
+        fmt.Fprintln(stderr, err)
+        return 1
+    }
+
+    if *f.drain != "" && !slices.Contains(game.Players(), *f.drain) {
+        fmt.Fprintf(stderr, "the resting player %q is not one of the players %v\n", *f.drain, game.Players())
+        return 2
+    }
+
+    interrupts := make(chan os.Signal, 1)
+    signal.Notify(interrupts, os.Interrupt)
+    defer signal.Stop(interrupts)
+
+    if *f.http != "" {
+
+        server := &http.Server{Addr: *f.http, Handler: statusHandler(game, metrics)}
+
+        go func() {
+
+            if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+                logger.Logf("http", "%v", err)
+            }
+        }()
+
+        defer server.Close()
+    }
+
+    //
+    // the reporter runs until the game ends, and playGame() waits for it as well
+    //
+    stopReporting := make(chan struct{})
+    reporterDone := make(chan struct{})
+
+    if *f.interval > 0 && !*f.quiet && !*f.visualize {
+
+        go func() {
+            defer close(reporterDone)
+            reportThroughput(logger, metrics, *f.interval, stopReporting)
+        }()
+
+    } else {
+        close(reporterDone)
+    }
+
+    //
+    // with -pausable every line typed on stdin, Enter alone is enough, pauses or
+    // resumes the game
+    //
+    if *f.pausable {
+
+        logger.Logf("game", "press Enter to pause or resume the game")
+
+        go func() {
+
+            scanner := bufio.NewScanner(os.Stdin)
+            paused := false
+
+            for scanner.Scan() {
+
+                if paused {
+                    game.Resume()
+                } else {
+                    game.Pause()
+                }
+
+                paused = !paused
+            }
+        }()
+    }
+
+    ctx := context.Background()
+
+    if *f.timeout > 0 {
+
+        var cancel context.CancelFunc
+        ctx, cancel = context.WithTimeout(ctx, *f.timeout)
+        defer cancel()
+    }
+
+    game.StartContext(ctx)
+
+    if *f.procs > 0 {
+        logger.Logf("game", "running with GOMAXPROCS %d", runtime.GOMAXPROCS(0))
+    }
+
+    //
+    // the renderer, or the JSON writer, is the only one writing the events, until
+    // their channel is closed with the end of the game, and the summary waits for it
+    //
+    rendered := make(chan struct{})
+
+    switch {
+    case *f.visualize:
+
+        go func() {
+            defer close(rendered)
+            render(stdout, newRingRenderer(game.Players(), *f.token), game.Events())
+        }()
+
+    case *f.json:
+
+        go func() {
+            defer close(rendered)
+            writeJSON(stdout, game.Events())
+        }()
+
+    default:
+        close(rendered)
+    }
+
+    errs := game.Errors()
+    var failures []error
+    draining := false
+
+    for errs != nil {
+
+        select {
+        case err, ok := <-errs:
+
+            if !ok {
+                errs = nil
+                break
+            }
+
+            failures = append(failures, err)
+
+        case <-interrupts:
+
+            if *f.drain != "" && !draining {
+                logger.Logf("game", "draining, the token will rest with %s", *f.drain)
+                draining = true
+                go game.Drain(*f.drain)
+                break
+            }
+
+            game.Stop()
+        }
+    }
+
+    close(stopReporting)
+    <-reporterDone
+    <-rendered
+
+    if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+        logger.Logf("game", "time is up, the game ended after %s", *f.timeout)
+    }
+
+    if min, avg, max, count := metrics.Latency(); count > 0 {
+        logger.Logf("metrics", "handoff latency: min %s, avg %s, max %s over %d handoffs", min, avg, max, count)
+    }
+
+    if dropped := game.DroppedEvents(); dropped > 0 {
+        logger.Logf("game", "%d events were dropped, the output could not keep up", dropped)
+    }
+
+    for _, handoff := range game.History() {
+        logger.Logf("history", "hop %d: %s -> %s at %s", handoff.Hop, handoff.From, handoff.To, handoff.Time.Format("15:04:05.000000"))
+    }
+
+    reportStats(logger, game.Stats())
+    logger.Logf("stats", "the token carries the sum %d", game.Sum())
+
+    if len(failures) > 0 {
+
+        fmt.Fprintf(stderr, "the game failed, %d player(s) reported errors:\n", len(failures))
+
+        for _, err := range failures {
+            fmt.Fprintf(stderr, "  %v\n", err)
+        }
+
+        return 1
+    }
+
+    return 0
+}
+
+// reportThroughput prints, every interval, how many handoffs per second happened
+// since the previous report and overall, until stop is closed
+func reportThroughput(logger Logger, metrics *Metrics, interval time.Duration, stop <-chan struct{}) {
+
+    ticker := time.NewTicker(interval)
+    defer ticker.Stop()
+
+    start := time.Now()
+    last := start
+    var lastHandoffs int64
+
+    for {
+
+        select {
+        case now := <-ticker.C:
+
+            handoffs := metrics.Handoffs()
+
+            logger.Logf("metrics", "throughput: %.2f exchanges/sec, %.2f exchanges/sec overall, %d exchanges",
+                float64(handoffs - lastHandoffs) / now.Sub(last).Seconds(),
+                float64(handoffs) / now.Sub(start).Seconds(),
+                handoffs)
+
+            last = now
+            lastHandoffs = handoffs
+
+        case <-stop:
+            return
+        }
+    }
+}
+
+// reportStats logs what every player did, and the totals. The totals match unless
+// the game was stopped with tokens still in a buffer
+func reportStats(logger Logger, stats []PlayerStats) {
+
+    sent, received := 0, 0
+
+    for _, player := range stats {
+        logger.Logf("stats", "%s: sent %d, received %d, held the token for %s", player.Player, player.Sent, player.Received, player.Active.Round(time.Millisecond))
+        sent += player.Sent
+        received += player.Received
+    }
+
+    logger.Logf("stats", "total: sent %d, received %d", sent, received)
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+    "bytes"
+    "encoding/json"
+    "flag"
+    "strings"
+    "testing"
+)
+
+// playWithFlags plays a game with the command line flags, and returns what it
+// wrote on the standard output and on the standard error
+func playWithFlags(t *testing.T, args ...string) (string, string) {
+
+    t.Helper()
+
+    flags := flag.NewFlagSet("test", flag.ContinueOnError)
+    f := addGameFlags(flags)
+
+    if err := flags.Parse(args); err != nil {
+        t.Fatal(err)
+    }
+
+    var stdout, stderr bytes.Buffer
+
+    if code := playGame(f, f.options(), &stdout, &stderr); code != 0 {
+        t.Fatalf("the game exited with %d:\n%s", code, stderr.String())
+    }
+
+    return stdout.String(), stderr.String()
+}
+
+func TestQuietModePrintsOnlyTheSummary(t *testing.T) {
+
+    output, _ := playWithFlags(t, "-delay", "0", "-rounds", "3", "-metrics", "0")
+
+    if !strings.Contains(output, "read the token") {
+        t.Fatalf("without -quiet the handoffs are not logged:\n%s", output)
+    }
+
+    output, _ = playWithFlags(t, "-quiet", "-delay", "0", "-rounds", "3", "-metrics", "0")
+
+    //
+    // every line comes from the summary, none from a player
+    //
+    for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+
+        if fields := strings.Fields(line); len(fields) < 2 || (fields[1] != "stats:" && fields[1] != "metrics:") {
+            t.Errorf("%q is not part of the summary", line)
+        }
+    }
+
+    for _, summary := range []string{"stats: A: sent 3, received 3", "stats: B: sent 3, received 3", "stats: total: sent 6, received 6"} {
+
+        if !strings.Contains(output, summary) {
+            t.Errorf("the summary lacks %q:\n%s", summary, output)
+        }
+    }
+}
+
+func TestVisualizeWritesOnlyFramesOnStdout(t *testing.T) {
+
+    output, _ := playWithFlags(t, "-visualize", "-delay", "0", "-rounds", "3", "-metrics", "0")
+
+    //
+    // every frame starts by going back to the beginning of the line, the summary
+    // and any other message of the game are on the standard error
+    //
+    frames := strings.Split(strings.TrimSuffix(output, "\n"), "\r")
+
+    if len(frames) < 2 || frames[0] != "" {
+        t.Fatalf("the output does not start with a frame: %q", output)
+    }
+
+    for _, frame := range frames[1:] {
+
+        if !strings.Contains(frame, " hop ") || strings.Contains(frame, "\n") {
+            t.Errorf("%q is not a frame of the ring", frame)
+        }
+    }
+}
+
+func TestJSONWritesOnlyEventsOnStdout(t *testing.T) {
+
+    output, summary := playWithFlags(t, "-json", "-delay", "0", "-rounds", "3", "-metrics", "0")
+
+    //
+    // every line of the standard output is an event, the hop of a send is received
+    // before the next one is sent
+    //
+    lines := strings.Split(strings.TrimSuffix(output, "\n"), "\n")
+
+    if len(lines) != 12 {
+        t.Fatalf("3 rounds of 2 players make 12 events, got %d lines:\n%s", len(lines), output)
+    }
+
+    for i, line := range lines {
+
+        var e Event
+
+        if err := json.Unmarshal([]byte(line), &e); err != nil {
+            t.Fatalf("%q is not an event: %v", line, err)
+        }
+
+        action, hop := Send, i / 2 + 1
+
+        if i % 2 == 1 {
+            action = Receive
+        }
+
+        if e.Action != action || e.Hop != hop || e.Player == "" || e.Time.IsZero() {
+            t.Errorf("event %d is %+v, want %s of hop %d", i, e, action, hop)
+        }
+    }
+
+    if !strings.Contains(summary, "stats: total: sent 6, received 6") {
+        t.Errorf("the summary is not on the standard error:\n%s", summary)
+    }
+}
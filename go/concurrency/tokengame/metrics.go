@@ -0,0 +1,159 @@
+package main
+
+import (
+    "sync"
+    "sync/atomic"
+    "time"
+)
+
+// Metrics counts token handoffs and measures how long they take. It is safe for
+// concurrent use
+type Metrics struct {
+
+    handoffs atomic.Int64
+
+    mutex sync.Mutex
+    latencies int
+    totalLatency time.Duration
+    minLatency time.Duration
+    maxLatency time.Duration
+}
+
+// Handoffs returns how many times the token was passed so far
+func (m *Metrics) Handoffs() int64 {
+    return m.handoffs.Load()
+}
+
+// handoff records a token handoff, nil Metrics ignore it
+func (m *Metrics) handoff() {
+
+    if m != nil {
+        m.handoffs.Add(1)
+    }
+}
+
+// Latency returns the shortest, average and longest time the token spent between
+// two players, over count handoffs
+func (m *Metrics) Latency() (min, avg, max time.Duration, count int) {
+
+    m.mutex.Lock()
+    defer m.mutex.Unlock()
+
+    if m.latencies > 0 {
+        avg = m.totalLatency / time.Duration(m.latencies)
+    }
+
+    return m.minLatency, avg, m.maxLatency, m.latencies
+}
+
+// latency records how long a handoff took, nil Metrics ignore it
+func (m *Metrics) latency(d time.Duration) {
+
+    if m == nil {
+        return
+    }
+
+    m.mutex.Lock()
+    defer m.mutex.Unlock()
+
+    if m.latencies == 0 || d < m.minLatency {
+        m.minLatency = d
+    }
+
+    if d > m.maxLatency {
+        m.maxLatency = d
+    }
+
+    m.latencies ++
+    m.totalLatency += d
+}
+
+// PlayerStats sums up what a player did during a game. Every token sent is either
+// received or still on its way, in a buffer or in flight, when the game ends
+type PlayerStats struct {
+    Player string
+
+    // Sent and Received count the completed sends and receives of the token
+    Sent int
+    Received int
+
+    // Active is how long the player held the token
+    Active time.Duration
+
+    // holdingSince is when the player got the token, zero while it does not have it
+    holdingSince time.Time
+}
+
+// hold starts the clock of the player, which just got the token
+func (s *PlayerStats) hold() {
+    s.holdingSince = time.Now()
+}
+
+// release stops the clock of the player, if it was holding the token
+func (s *PlayerStats) release() {
+
+    if !s.holdingSince.IsZero() {
+        s.Active += time.Since(s.holdingSince)
+        s.holdingSince = time.Time{}
+    }
+}
+
+func (s *PlayerStats) countReceive() {
+    s.Received ++
+    s.hold()
+}
+
+func (s *PlayerStats) countSend() {
+    s.Sent ++
+    s.release()
+}
+
+// statsCollector gathers the PlayerStats the players report when they return. It
+// is safe for concurrent use, and a nil collector gathers nothing
+type statsCollector struct {
+    mutex sync.Mutex
+    players map[string]PlayerStats
+}
+
+func newStatsCollector() *statsCollector {
+    return &statsCollector{players: make(map[string]PlayerStats)}
+}
+
+func (c *statsCollector) report(stats PlayerStats) {
+
+    if c == nil {
+        return
+    }
+
+    c.mutex.Lock()
+    defer c.mutex.Unlock()
+
+    c.players[stats.Player] = stats
+}
+
+// get returns the stats of the players in the given order. A player that did not
+// report yet has zero stats
+func (c *statsCollector) get(names []string) []PlayerStats {
+
+    stats := make([]PlayerStats, len(names))
+
+    for i, name := range names {
+        stats[i].Player = name
+    }
+
+    if c == nil {
+        return stats
+    }
+
+    c.mutex.Lock()
+    defer c.mutex.Unlock()
+
+    for i, name := range names {
+
+        if reported, ok := c.players[name]; ok {
+            stats[i] = reported
+        }
+    }
+
+    return stats
+}
@@ -0,0 +1,326 @@
+package main
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "math/rand"
+    "time"
+)
+
+// ErrStalled is returned by a player that did not get the token in time
+var ErrStalled = errors.New("stalled")
+
+// ErrTokenLost is returned instead of ErrStalled by a player that did not get the
+// token in time because another player dropped it
+var ErrTokenLost = errors.New("token lost")
+
+// ErrPanicked is returned by a player that panicked. The panic is recovered, so
+// the other players are cancelled and the process carries on
+var ErrPanicked = errors.New("panicked")
+
+// Token is what the players pass to each other, carrying a payload of any type
+type Token[T any] struct {
+
+    // Value is the payload, set by the starter and passed along
+    Value T
+
+    // Hops counts how many times the token was passed. Each player increments it
+    // before sending the token on, so the first handoff is hop 1, and a game of n
+    // handoffs numbers them 1 to n. Zero means the token was never sent
+    Hops int
+
+    // Sum accumulates the seats of the players, counting from 1, each adding its
+    // own before sending the token on. The token is copied on every send, so the
+    // players never share it. Once every player sent the token the same number of
+    // rounds, the sum is rounds * n * (n + 1) / 2 for n players
+    Sum int
+
+    // SentAt is set right before the token is sent, so the receiver can tell how
+    // long the handoff itself took, sleeping excluded
+    SentAt time.Time
+}
+
+// each player function runs on its own thread, waits for the token on the inbound
+// channel and passes it on the outbound channel. The player returns when the
+// context is cancelled, regardless of whether it is sending or receiving, or when
+// it gets the token back after sending it the maximum number of rounds. In that
+// case the player closes its outbound channel instead of sending, so the next
+// player, which has completed its rounds as well, also stops. A closed inbound
+// channel, whoever closed it, ends the player the same way: it never sends again
+// and it closes its outbound channel, which only the player itself ever closes,
+// so there is never a send on a closed channel. A player waiting longer than the
+// stall timeout for the token returns ErrStalled. The token can carry any payload,
+// the starter seeds it with the initial value, and every player adds its seat to
+// the Sum of the token
+func player[T any](ctx context.Context, config Config, name string, seat int, iHaveTheToken bool, in <-chan Token[T], out chan<- Token[T], initial T) error {
+
+    token := Token[T]{Value: initial}
+    stats := PlayerStats{Player: name}
+
+    if iHaveTheToken {
+        stats.hold()
+    }
+
+    defer func() {
+        stats.release()
+        config.stats.report(stats)
+    }()
+
+    //
+    // in deterministic mode each line starts with the number of the handoff it
+    // belongs to, and nothing is logged once the token left, because by then the
+    // next player may be logging already
+    //
+    logf := func(format string, args ...any) {
+
+        if config.Deterministic {
+            format = "%06d " + format
+            args = append([]any{token.Hops}, args...)
+        }
+
+        config.logf(name, format, args...)
+    }
+
+    //
+    // we go in a loop and exchange the token
+    //
+    for {
+
+        if iHaveTheToken {
+
+            if config.MaxRounds > 0 && stats.Sent == config.MaxRounds {
+
+                logf("stopping after sending the token %d times", stats.Sent)
+                close(out)
+                return nil
+            }
+
+            if config.drain.restsWith(name) {
+
+                logf("keeping the token, the game is drained")
+                close(out)
+                return nil
+            }
+
+            //
+            // while the game is paused keep the token
+            //
+            if config.gate.isPaused() {
+                logf("holding the token while the game is paused")
+            }
+
+            if !config.gate.pass(ctx) {
+                logf("shutting down")
+                return nil
+            }
+
+            //
+            // put it on the channel
+            //
+
+            token.Hops ++
+            token.Sum += seat
+            logf("added %d, the sum is now %d", seat, token.Sum)
+
+            if config.BeforeSend != nil {
+                config.BeforeSend(name, token.Hops)
+            }
+
+            //
+            // a select picks randomly among the ready cases, so look for the end of
+            // the game first instead of handing the token to a player that leaves
+            //
+            if ctx.Err() != nil {
+                logf("shutting down")
+                return nil
+            }
+
+            if config.DropProbability > 0 && rand.Float64() < config.DropProbability {
+
+                //
+                // pretend the token was sent, and wait for it like any other player
+                //
+                logf("dropped the token")
+                config.lost.record(name, token.Hops)
+                stats.release()
+                iHaveTheToken = false
+                continue
+            }
+
+            logf("writing the token on the channel ...")
+
+            config.events.publish(name, Send, token.Hops)
+            token.SentAt = time.Now()
+
+            if !send(ctx, config, name, out, token) {
+                logf("shutting down")
+                return nil
+            }
+
+            stats.countSend()
+            config.Metrics.handoff()
+
+            //
+            // an unbuffered send returns only once the receiver has the token, a
+            // buffered one as soon as there is room, whether anybody reads or not
+            //
+            if !config.Deterministic {
+
+                if cap(out) == 0 {
+                    logf("handed the token over")
+                } else {
+                    logf("left the token in the buffer (%d/%d) without waiting for the receiver", len(out), cap(out))
+                }
+            }
+
+        } else {
+
+            //
+            // wait to get the token
+            //
+
+            var received Token[T]
+            var ok bool
+
+            //
+            // a nil channel never fires, so without a stall timeout we wait forever
+            //
+            var stall <-chan time.Time
+
+            for waiting := true; waiting; {
+
+                pauses := config.gate.pauseCount()
+
+                if config.StallTimeout > 0 {
+                    stall = time.After(config.StallTimeout)
+                }
+
+                select {
+                case received, ok = <-in:
+
+                    if ok {
+                        config.Metrics.latency(time.Since(received.SentAt))
+                    }
+
+                    waiting = false
+                case <-stall:
+
+                    //
+                    // no token is expected while the game is paused, start over
+                    //
+                    if config.gate.pausedSince(pauses) {
+                        break
+                    }
+
+                    if how, lost := config.lost.get(); lost {
+                        logf("stalled, the token was lost, %s", how)
+                        return fmt.Errorf("%s: %w, %s", name, ErrTokenLost, how)
+                    }
+
+                    logf("stalled, no token for %s", config.StallTimeout)
+                    return fmt.Errorf("%s: %w, no token for %s", name, ErrStalled, config.StallTimeout)
+                case <-ctx.Done():
+                    logf("shutting down")
+                    return nil
+                }
+            }
+
+            if !ok {
+
+                //
+                // the previous player stopped, pass the news on
+                //
+
+                logf("stopping after sending the token %d times, the inbound channel was closed", stats.Sent)
+                close(out)
+                return nil
+            }
+
+            token = received
+            stats.countReceive()
+            config.holder.set(name, token.Sum)
+            config.history.record(name, token.Hops)
+            config.events.publish(name, Receive, token.Hops)
+
+            logf("read the token %v from the channel, hop %d", token.Value, token.Hops)
+
+            delay := config.jitter.delay(config.delayOf(name))
+
+            if config.Verbose {
+                logf("the token was sent at %s and arrived after %s, holding it for %s", token.SentAt.Format("15:04:05.000000"), time.Since(token.SentAt), delay)
+            }
+
+            if !sleepCtx(ctx, delay) {
+                logf("shutting down")
+                return nil
+            }
+        }
+
+        iHaveTheToken = !iHaveTheToken
+    }
+}
+
+// send puts the token on the channel, and returns false if the context is done
+// first. A send blocked for longer than the backpressure timeout on a full buffer
+// is reported instead of blocking silently, and the sender keeps waiting
+func send[T any](ctx context.Context, config Config, source string, out chan<- Token[T], token Token[T]) bool {
+
+    var backpressure <-chan time.Time
+
+    if config.Backpressure > 0 && cap(out) > 0 {
+        ticker := time.NewTicker(config.Backpressure)
+        defer ticker.Stop()
+        backpressure = ticker.C
+    }
+
+    blocked := time.Now()
+
+    for {
+
+        select {
+        case out <- token:
+            return true
+        case <-backpressure:
+            config.logf(source, "backpressure: buffer full (%d/%d), the send is blocked for %s", len(out), cap(out), time.Since(blocked).Round(time.Millisecond))
+        case <-ctx.Done():
+            return false
+        }
+    }
+}
+
+// play runs the player implementation the configuration asks for. A panic of the
+// player is recovered and returned as ErrPanicked, so that it ends the game like
+// any other error instead of the whole process
+func play[T any](ctx context.Context, config Config, name string, seat int, starts bool, in <-chan Token[T], out chan<- Token[T], initial T) (err error) {
+
+    defer func() {
+
+        if r := recover(); r != nil {
+            config.logf(name, "panicked: %v", r)
+            err = fmt.Errorf("%s: %w: %v", name, ErrPanicked, r)
+        }
+    }()
+
+    if config.Select {
+        return selectPlayer(ctx, config, name, seat, starts, in, out, initial)
+    }
+
+    return player(ctx, config, name, seat, starts, in, out, initial)
+}
+
+// sleepCtx sleeps for d, and returns false if the context is done first, so that
+// a player holding the token does not delay the end of the game
+func sleepCtx(ctx context.Context, d time.Duration) bool {
+
+    if d <= 0 {
+        return ctx.Err() == nil
+    }
+
+    select {
+    case <-time.After(d):
+        return true
+    case <-ctx.Done():
+        return false
+    }
+}
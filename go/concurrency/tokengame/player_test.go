@@ -0,0 +1,240 @@
+package main
+
+import (
+    "bytes"
+    "context"
+    "strings"
+    "testing"
+    "time"
+)
+
+// startPlayer runs a player starting with the token, and returns a function that
+// stops it and waits for it to return
+func startPlayer(in <-chan Token[string], out chan<- Token[string]) func() {
+
+    ctx, cancel := context.WithCancel(context.Background())
+    done := make(chan struct{})
+
+    go func() {
+        defer close(done)
+        player(ctx, Config{}, "A", 1, true, in, out, ".")
+    }()
+
+    return func() {
+        cancel()
+        <-done
+    }
+}
+
+func TestBufferedSendsDoNotWaitForTheReceiver(t *testing.T) {
+
+    //
+    // nobody reads the buffer: the test hands the token back to A directly, which
+    // A only takes once its previous send completed
+    //
+    in := make(chan Token[string])
+    buffered := make(chan Token[string], 3)
+
+    stop := startPlayer(in, buffered)
+    defer stop()
+
+    for i := 1; i <= 3; i++ {
+
+        select {
+        case in <- Token[string]{Hops: i}:
+        case <-time.After(time.Second):
+            t.Fatalf("send %d did not complete", i)
+        }
+    }
+
+    if len(buffered) != 3 {
+        t.Fatalf("the buffer holds %d tokens, want 3", len(buffered))
+    }
+
+    //
+    // without a receiver, the very first send on an unbuffered channel blocks
+    //
+    in = make(chan Token[string])
+    stop = startPlayer(in, make(chan Token[string]))
+    defer stop()
+
+    select {
+    case in <- Token[string]{}:
+        t.Fatal("a send on an unbuffered channel completed without a receiver")
+    case <-time.After(20 * time.Millisecond):
+    }
+}
+
+func TestClosedChannelEndsBothPlayers(t *testing.T) {
+
+    //
+    // the test feeds A and reads what B sends, A passes on to B
+    //
+    toA := make(chan Token[string])
+    aToB := make(chan Token[string])
+    fromB := make(chan Token[string])
+
+    errs := make(chan error, 2)
+
+    go func() { errs <- player(context.Background(), Config{}, "A", 1, false, toA, aToB, ".") }()
+    go func() { errs <- player(context.Background(), Config{}, "B", 2, false, aToB, fromB, ".") }()
+
+    toA <- Token[string]{Value: ".", Hops: 1}
+
+    if token := <-fromB; token.Hops != 3 {
+        t.Fatalf("the token came back at hop %d, want 3", token.Hops)
+    }
+
+    close(toA)
+
+    for i := 0; i < 2; i++ {
+
+        select {
+        case err := <-errs:
+
+            if err != nil {
+                t.Error(err)
+            }
+
+        case <-time.After(time.Second):
+            t.Fatal("a player did not return after its inbound channel was closed")
+        }
+    }
+
+    //
+    // B passed the news on by closing its own outbound channel
+    //
+    if _, ok := <-fromB; ok {
+        t.Fatal("B did not close its outbound channel")
+    }
+}
+
+// relayPayload starts a player holding a token with the initial payload, and
+// returns the payload of the token it sends, then of the one it passes on after
+// the test handed it a token carrying next
+func relayPayload[T any](t *testing.T, initial, next T) (T, T) {
+
+    t.Helper()
+
+    in := make(chan Token[T])
+    out := make(chan Token[T])
+
+    ctx, cancel := context.WithCancel(context.Background())
+    done := make(chan struct{})
+
+    go func() {
+        defer close(done)
+        player(ctx, Config{}, "A", 1, true, in, out, initial)
+    }()
+
+    defer func() {
+        cancel()
+        <-done
+    }()
+
+    sent := <-out
+    in <- Token[T]{Value: next, Hops: sent.Hops + 1}
+    passed := <-out
+
+    return sent.Value, passed.Value
+}
+
+func TestPlayerCarriesAnIntPayload(t *testing.T) {
+
+    sent, passed := relayPayload(t, 42, 7)
+
+    if sent != 42 || passed != 7 {
+        t.Fatalf("the player sent %d then %d, want 42 then 7", sent, passed)
+    }
+}
+
+func TestPlayerCarriesAStructPayload(t *testing.T) {
+
+    type order struct {
+        Item string
+        Quantity int
+    }
+
+    tea, cake := order{Item: "tea", Quantity: 3}, order{Item: "cake", Quantity: 1}
+    sent, passed := relayPayload(t, tea, cake)
+
+    if sent != tea || passed != cake {
+        t.Fatalf("the player sent %v then %v, want %v then %v", sent, passed, tea, cake)
+    }
+}
+
+func TestSendReportsBackpressureOnAFullBuffer(t *testing.T) {
+
+    var output bytes.Buffer
+    logger := NewLogger(&output)
+
+    //
+    // nobody ever drains the buffer, so the send blocks until the deadline
+    //
+    full := make(chan Token[string], 1)
+    full <- Token[string]{Value: ".", Hops: 1}
+
+    ctx, cancel := context.WithTimeout(context.Background(), 50 * time.Millisecond)
+    defer cancel()
+
+    config := Config{Backpressure: 10 * time.Millisecond, Logger: logger}
+
+    if send(ctx, config, "A", full, Token[string]{Value: ".", Hops: 2}) {
+        t.Fatal("a send on a full buffer completed")
+    }
+
+    logger.Close()
+
+    if !strings.Contains(output.String(), "A: backpressure: buffer full (1/1)") {
+        t.Fatalf("the blocked send was not reported:\n%s", output.String())
+    }
+}
+
+func TestPlayersAlternateTheHandoffs(t *testing.T) {
+
+    var output bytes.Buffer
+    logger := NewLogger(&output)
+
+    config := Config{MaxRounds: 3, Logger: logger}
+    aToB := make(chan Token[string])
+    bToA := make(chan Token[string])
+
+    errs := make(chan error, 2)
+
+    go func() { errs <- player(context.Background(), config, "A", 1, true, bToA, aToB, ".") }()
+    go func() { errs <- player(context.Background(), config, "B", 2, false, aToB, bToA, ".") }()
+
+    for i := 0; i < 2; i++ {
+
+        select {
+        case err := <-errs:
+
+            if err != nil {
+                t.Error(err)
+            }
+
+        case <-time.After(time.Second):
+            t.Fatal("a player did not return after its rounds")
+        }
+    }
+
+    logger.Close()
+
+    //
+    // a player logs the read before passing the token on, so the reads are in order
+    //
+    var reads []string
+
+    for _, message := range logMessages(output.String()) {
+
+        if name, text, _ := strings.Cut(message, ": "); strings.HasPrefix(text, "read the token") {
+            reads = append(reads, name)
+        }
+    }
+
+    want := []string{"B", "A", "B", "A", "B", "A"}
+
+    if strings.Join(reads, " ") != strings.Join(want, " ") {
+        t.Fatalf("the token was read by %v, want %v:\n%s", reads, want, output.String())
+    }
+}
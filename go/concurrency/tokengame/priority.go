@@ -0,0 +1,195 @@
+package main
+
+import (
+    "context"
+    "flag"
+    "fmt"
+    "os"
+    "os/signal"
+    "strings"
+    "sync"
+    "time"
+)
+
+// StartPriority shows how a select can prefer one channel over another. A
+// sender queues `tokens` normal tokens, named N1, N2 ..., on the normal channel,
+// and the player P handles them one at a time, holding each for the configured
+// delay. Once P handled `inject` of them, possibly none, the sender puts a token
+// named "priority" on a second, dedicated channel, which P handles next although
+// normal tokens are still waiting. The call returns the names of the tokens in
+// the order P handled them, and an error if the priority token did not overtake
+// the normal ones
+func StartPriority(ctx context.Context, config Config, tokens, inject int) ([]string, error) {
+
+    if tokens < 1 {
+        return nil, fmt.Errorf("at least one normal token is needed, got %d", tokens)
+    }
+
+    if inject < 0 || inject >= tokens {
+        return nil, fmt.Errorf("the priority token must be injected after 0 to %d normal tokens, got %d", tokens - 1, inject)
+    }
+
+    //
+    // all the normal tokens are queued up front, so there are always some waiting
+    // when the priority token arrives
+    //
+    normal := make(chan Token[string], tokens)
+    priority := make(chan Token[string], 1)
+
+    for i := 1; i <= tokens; i++ {
+        normal <- Token[string]{Value: fmt.Sprintf("N%d", i), Hops: 1, SentAt: time.Now()}
+    }
+
+    close(normal)
+
+    //
+    // P reports every token it handled on handled, and waits on proceed before
+    // taking the next one, so the sender injects the priority token at a known point
+    //
+    handled := make(chan string)
+    proceed := make(chan struct{})
+
+    var order []string
+    var waitGroup sync.WaitGroup
+
+    //
+    // the priority channel is buffered, so injecting never blocks the sender
+    //
+    injectPriority := func() {
+        config.logf("sender", "injecting the priority token, %d normal tokens are waiting", len(normal))
+        priority <- Token[string]{Value: "priority", Hops: 1, SentAt: time.Now()}
+        close(priority)
+    }
+
+    if inject == 0 {
+        injectPriority()
+    }
+
+    waitGroup.Add(1)
+
+    go func() {
+
+        defer waitGroup.Done()
+        defer close(handled)
+
+        in, urgent := normal, priority
+
+        for in != nil || urgent != nil {
+
+            var token Token[string]
+            var ok bool
+
+            //
+            // look at the priority channel first, and fall back to waiting on both
+            // only when it has nothing. A single select would pick at random among
+            // the ready channels
+            //
+            select {
+            case token, ok = <-urgent:
+
+                if !ok {
+                    urgent = nil
+                    continue
+                }
+
+            default:
+
+                select {
+                case token, ok = <-urgent:
+
+                    if !ok {
+                        urgent = nil
+                        continue
+                    }
+
+                case token, ok = <-in:
+
+                    if !ok {
+                        in = nil
+                        continue
+                    }
+
+                case <-ctx.Done():
+                    config.logf("P", "shutting down")
+                    return
+                }
+            }
+
+            config.Metrics.latency(time.Since(token.SentAt))
+            config.events.publish("P", Receive, token.Hops)
+            config.logf("P", "handling the %s token", token.Value)
+
+            if !sleepCtx(ctx, config.Delay) {
+                config.logf("P", "shutting down")
+                return
+            }
+
+            select {
+            case handled <- token.Value:
+            case <-ctx.Done():
+                config.logf("P", "shutting down")
+                return
+            }
+
+            select {
+            case <-proceed:
+            case <-ctx.Done():
+                config.logf("P", "shutting down")
+                return
+            }
+        }
+
+        config.logf("P", "handled all the tokens")
+    }()
+
+    for name := range handled {
+
+        order = append(order, name)
+
+        if len(order) == inject {
+            injectPriority()
+        }
+
+        select {
+        case proceed <- struct{}{}:
+        case <-ctx.Done():
+        }
+    }
+
+    waitGroup.Wait()
+
+    if err := ctx.Err(); err != nil {
+        return order, err
+    }
+
+    if len(order) != tokens + 1 || order[inject] != "priority" {
+        return order, fmt.Errorf("the priority token did not overtake the normal ones, the order was %v", order)
+    }
+
+    return order, nil
+}
+
+func runPriority(args []string) int {
+
+    flags := flag.NewFlagSet("priority", flag.ExitOnError)
+    delay := flags.Duration("delay", 500 * time.Millisecond, "how long the player holds a token, as if working on it")
+    tokens := flags.Int("tokens", 5, "how many normal tokens are queued")
+    inject := flags.Int("inject", 2, "how many normal tokens are handled before the priority token is injected")
+    flags.Parse(args)
+
+    ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+    defer stop()
+
+    logger := NewLogger(os.Stdout)
+    defer logger.Close()
+
+    order, err := StartPriority(ctx, Config{Delay: *delay, Logger: logger}, *tokens, *inject)
+    logger.Logf("P", "handled the tokens in the order %s", strings.Join(order, ", "))
+
+    if err != nil {
+        fmt.Fprintln(os.Stderr, err)
+        return 1
+    }
+
+    return 0
+}
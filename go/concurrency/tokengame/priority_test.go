@@ -0,0 +1,39 @@
+package main
+
+import (
+    "context"
+    "fmt"
+    "slices"
+    "testing"
+    "time"
+)
+
+func TestPriorityTokenOvertakesTheWaitingOnes(t *testing.T) {
+
+    tokens := 4
+
+    for inject := 0; inject < tokens; inject++ {
+
+        order, err := StartPriority(context.Background(), Config{Delay: time.Millisecond}, tokens, inject)
+
+        if err != nil {
+            t.Fatalf("inject %d: %v", inject, err)
+        }
+
+        //
+        // the normal tokens keep their order, with the priority token right after
+        // the ones handled before it was injected
+        //
+        var want []string
+
+        for i := 1; i <= tokens; i++ {
+            want = append(want, fmt.Sprintf("N%d", i))
+        }
+
+        want = slices.Insert(want, inject, "priority")
+
+        if !slices.Equal(order, want) {
+            t.Errorf("inject %d: the tokens were handled in the order %v, want %v", inject, order, want)
+        }
+    }
+}
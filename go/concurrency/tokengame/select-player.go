@@ -0,0 +1,136 @@
+package main
+
+import (
+    "context"
+    "fmt"
+    "time"
+)
+
+// selectPlayer plays like player, without deciding up front whether to send or
+// to receive: a single select is ready for both, and whichever operation can
+// proceed fires. What keeps the exchange correct is the outbound channel of the
+// select, which is nil, and so never ready, unless the player has the token.
+// With exactly one token around, the inbound channel only fires while the player
+// has none
+func selectPlayer[T any](ctx context.Context, config Config, name string, seat int, starts bool, in <-chan Token[T], out chan<- Token[T], initial T) error {
+
+    var pending chan<- Token[T]
+    var token Token[T]
+    stats := PlayerStats{Player: name}
+
+    defer func() {
+        stats.release()
+        config.stats.report(stats)
+    }()
+
+    if starts {
+
+        stats.hold()
+
+        //
+        // the first send waits for a paused game, and does not happen in a drained
+        // game resting with the starter, like every later send
+        //
+        if !config.gate.pass(ctx) {
+            config.logf(name, "shutting down")
+            return nil
+        }
+
+        if config.drain.restsWith(name) {
+            config.logf(name, "keeping the token, the game is drained")
+            close(out)
+            return nil
+        }
+
+        token = Token[T]{Value: initial, Hops: 1, Sum: seat, SentAt: time.Now()}
+        pending = out
+
+        if config.BeforeSend != nil {
+            config.BeforeSend(name, token.Hops)
+        }
+    }
+
+    for {
+
+        var stall <-chan time.Time
+
+        if pending == nil && config.StallTimeout > 0 {
+            stall = time.After(config.StallTimeout)
+        }
+
+        pauses := config.gate.pauseCount()
+
+        select {
+        case pending <- token:
+
+            pending = nil
+            stats.countSend()
+            config.Metrics.handoff()
+            config.events.publish(name, Send, token.Hops)
+            config.logf(name, "passed the token on, hop %d", token.Hops)
+
+        case received, ok := <-in:
+
+            if !ok {
+                config.logf(name, "stopping after sending the token %d times, the inbound channel was closed", stats.Sent)
+                close(out)
+                return nil
+            }
+
+            config.Metrics.latency(time.Since(received.SentAt))
+            stats.countReceive()
+            config.holder.set(name, received.Sum)
+            config.history.record(name, received.Hops)
+            config.events.publish(name, Receive, received.Hops)
+            config.logf(name, "got the token %v, hop %d", received.Value, received.Hops)
+
+            delay := config.jitter.delay(config.delayOf(name))
+
+            if config.Verbose {
+                config.logf(name, "the token was sent at %s and arrived after %s, holding it for %s", received.SentAt.Format("15:04:05.000000"), time.Since(received.SentAt), delay)
+            }
+
+            if config.MaxRounds > 0 && stats.Sent == config.MaxRounds {
+                config.logf(name, "stopping after sending the token %d times", stats.Sent)
+                close(out)
+                return nil
+            }
+
+            if !sleepCtx(ctx, delay) || !config.gate.pass(ctx) {
+                config.logf(name, "shutting down")
+                return nil
+            }
+
+            if config.drain.restsWith(name) {
+                config.logf(name, "keeping the token, the game is drained")
+                close(out)
+                return nil
+            }
+
+            token = received
+            token.Hops ++
+            token.Sum += seat
+            token.SentAt = time.Now()
+            pending = out
+
+            config.logf(name, "added %d, the sum is now %d", seat, token.Sum)
+
+            if config.BeforeSend != nil {
+                config.BeforeSend(name, token.Hops)
+            }
+
+        case <-stall:
+
+            if config.gate.pausedSince(pauses) {
+                break
+            }
+
+            config.logf(name, "stalled, no token for %s", config.StallTimeout)
+            return fmt.Errorf("%s: %w, no token for %s", name, ErrStalled, config.StallTimeout)
+
+        case <-ctx.Done():
+            config.logf(name, "shutting down")
+            return nil
+        }
+    }
+}
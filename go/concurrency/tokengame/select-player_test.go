@@ -0,0 +1,106 @@
+package main
+
+import (
+    "context"
+    "testing"
+    "time"
+)
+
+func TestSelectPlayersPassASingleToken(t *testing.T) {
+
+    names := []string{"A", "B", "C"}
+
+    for _, buffer := range []int{0, 2} {
+
+        game := newTestGame(t, WithPlayerNames(names...), WithSelectPlayers(), WithDelay(0), WithRounds(20), WithBuffer(buffer), WithEvents(256))
+        game.Start()
+
+        var receives []Event
+
+        for e := range game.Events() {
+
+            if e.Action == Receive {
+                receives = append(receives, e)
+            }
+        }
+
+        if errs := collectErrors(game); len(errs) > 0 {
+            t.Fatalf("buffer %d: %v", buffer, errs)
+        }
+
+        if len(receives) != 20 * len(names) || game.DroppedEvents() != 0 {
+            t.Fatalf("buffer %d: got %d receives and %d dropped events, want %d and none", buffer, len(receives), game.DroppedEvents(), 20 * len(names))
+        }
+
+        //
+        // a second token would show as a hop received twice, or out of turn
+        //
+        for i, e := range receives {
+
+            if player := names[(i + 1) % len(names)]; e.Player != player || e.Hop != i + 1 {
+                t.Fatalf("buffer %d: receive %d was by %s at hop %d, want %s at hop %d", buffer, i, e.Player, e.Hop, player, i + 1)
+            }
+        }
+    }
+}
+
+func TestSelectPlayerStartsOnlyOnceResumed(t *testing.T) {
+
+    config := Config{gate: newPauseGate()}
+    config.gate.pause()
+
+    in := make(chan Token[string])
+    out := make(chan Token[string])
+
+    ctx, cancel := context.WithCancel(context.Background())
+    done := make(chan struct{})
+
+    go func() {
+        defer close(done)
+        selectPlayer(ctx, config, "A", 1, true, in, out, ".")
+    }()
+
+    defer func() {
+        cancel()
+        <-done
+    }()
+
+    select {
+    case <-out:
+        t.Fatal("the starter sent the token while the game was paused")
+    case <-time.After(50 * time.Millisecond):
+    }
+
+    config.gate.resume()
+
+    select {
+    case token := <-out:
+
+        if token.Hops != 1 {
+            t.Fatalf("the first send is hop %d, want 1", token.Hops)
+        }
+
+    case <-time.After(time.Second):
+        t.Fatal("the starter did not send the token once resumed")
+    }
+}
+
+func TestSelectPlayerKeepsTheTokenOfADrainedGame(t *testing.T) {
+
+    config := Config{gate: newPauseGate(), drain: newDrainer()}
+    config.drain.request("A")
+
+    in := make(chan Token[string])
+    out := make(chan Token[string], 1)
+
+    if err := selectPlayer(context.Background(), config, "A", 1, true, in, out, "."); err != nil {
+        t.Fatal(err)
+    }
+
+    //
+    // the token rests with the starter: nothing was sent, and out is closed
+    //
+    if token, ok := <-out; ok {
+        t.Fatalf("the starter of a drained game sent hop %d", token.Hops)
+    }
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+    "context"
+    "fmt"
+    "slices"
+    "sync"
+    "sync/atomic"
+    "time"
+)
+
+// pauseGate holds back the player that has the token while the game is paused,
+// so the token stays where it is and can be neither lost nor duplicated. It is
+// safe for concurrent use, and a nil gate never holds anybody back
+type pauseGate struct {
+
+    mutex sync.Mutex
+
+    // open is closed while the game runs, and replaced by a fresh channel on pause
+    open chan struct{}
+
+    // pauses counts the pauses, so a waiting player can tell whether the game was
+    // paused since it started waiting
+    pauses int
+}
+
+func newPauseGate() *pauseGate {
+
+    g := &pauseGate{open: make(chan struct{})}
+    close(g.open)
+    return g
+}
+
+func (g *pauseGate) pause() {
+
+    g.mutex.Lock()
+    defer g.mutex.Unlock()
+
+    select {
+    case <-g.open:
+        g.open = make(chan struct{})
+        g.pauses ++
+    default:
+        // already paused
+    }
+}
+
+func (g *pauseGate) resume() {
+
+    g.mutex.Lock()
+    defer g.mutex.Unlock()
+
+    select {
+    case <-g.open:
+        // already running
+    default:
+        close(g.open)
+    }
+}
+
+// state returns the channel that is closed while the game runs, and the number
+// of pauses so far
+func (g *pauseGate) state() (<-chan struct{}, int) {
+
+    g.mutex.Lock()
+    defer g.mutex.Unlock()
+
+    return g.open, g.pauses
+}
+
+// isPaused tells whether the game is paused now
+func (g *pauseGate) isPaused() bool {
+
+    if g == nil {
+        return false
+    }
+
+    open, _ := g.state()
+
+    select {
+    case <-open:
+        return false
+    default:
+        return true
+    }
+}
+
+// pausedSince tells whether the game is paused now, or was paused since the
+// given count was read from pauseCount
+func (g *pauseGate) pausedSince(pauses int) bool {
+    return g.isPaused() || g.pauseCount() != pauses
+}
+
+// pauseCount returns the number of pauses so far
+func (g *pauseGate) pauseCount() int {
+
+    if g == nil {
+        return 0
+    }
+
+    _, pauses := g.state()
+    return pauses
+}
+
+// pass returns once the game runs, or false if the context is cancelled first
+func (g *pauseGate) pass(ctx context.Context) bool {
+
+    if g == nil {
+        return true
+    }
+
+    open, _ := g.state()
+
+    select {
+    case <-open:
+        return true
+    case <-ctx.Done():
+        return false
+    }
+}
+
+// tokenHolder tracks which player has the token, and the sum the token carried
+// when that player got it. The receiver takes over when its receive completes, so
+// while a send is in flight, or while the token waits in a buffer, the sender
+// still counts as the holder. It is safe for concurrent use, and a nil holder
+// tracks nothing
+type tokenHolder struct {
+    holding atomic.Value
+}
+
+type holding struct {
+    name string
+    sum int
+}
+
+func (h *tokenHolder) set(name string, sum int) {
+
+    if h != nil {
+        h.holding.Store(holding{name: name, sum: sum})
+    }
+}
+
+func (h *tokenHolder) get() (string, int) {
+
+    if h == nil {
+        return "", 0
+    }
+
+    current, _ := h.holding.Load().(holding)
+    return current.name, current.sum
+}
+
+// Handoff is an entry of the history of a game: the token went from one player
+// to another at the given hop
+type Handoff struct {
+    From string
+    To string
+    Hop int
+    Time time.Time
+}
+
+// history records the handoffs in the order the receivers got the token. With a
+// single token the receives happen one after the other, so each handoff is from
+// the receiver of the previous one. It is safe for concurrent use, and a nil
+// history records nothing
+type history struct {
+    mutex sync.Mutex
+    holder string
+    handoffs []Handoff
+}
+
+func newHistory(starter string) *history {
+    return &history{holder: starter}
+}
+
+// record notes that the named player received the token at the given hop
+func (h *history) record(to string, hop int) {
+
+    if h == nil {
+        return
+    }
+
+    h.mutex.Lock()
+    defer h.mutex.Unlock()
+
+    h.handoffs = append(h.handoffs, Handoff{From: h.holder, To: to, Hop: hop, Time: time.Now()})
+    h.holder = to
+}
+
+func (h *history) get() []Handoff {
+
+    if h == nil {
+        return nil
+    }
+
+    h.mutex.Lock()
+    defer h.mutex.Unlock()
+
+    return slices.Clone(h.handoffs)
+}
+
+// drainer asks the players to end the game with the token at a resting player:
+// the token keeps going around until that player gets it, and the player then
+// ends the game the way a player done with its rounds does, by closing its
+// outbound channel. The first request wins. It is safe for concurrent use, and
+// a nil drainer never drains
+type drainer struct {
+    once sync.Once
+    requested chan struct{}
+    resting string
+}
+
+func newDrainer() *drainer {
+    return &drainer{requested: make(chan struct{})}
+}
+
+func (d *drainer) request(resting string) {
+
+    d.once.Do(func() {
+        d.resting = resting
+        close(d.requested)
+    })
+}
+
+// restsWith tells whether the named player, holding the token, must keep it and
+// stop because the game is drained
+func (d *drainer) restsWith(name string) bool {
+
+    if d == nil {
+        return false
+    }
+
+    select {
+    case <-d.requested:
+        return d.resting == name
+    default:
+        return false
+    }
+}
+
+// lostToken remembers who dropped the token, so that a stalled player can tell a
+// lost token from a slow one. It is safe for concurrent use, and a nil lostToken
+// remembers nothing
+type lostToken struct {
+    description atomic.Value
+}
+
+func (l *lostToken) record(player string, hop int) {
+
+    if l != nil {
+        l.description.Store(fmt.Sprintf("dropped by %s at hop %d", player, hop))
+    }
+}
+
+// get returns how the token was lost, or false if it was not
+func (l *lostToken) get() (string, bool) {
+
+    if l == nil {
+        return "", false
+    }
+
+    description, ok := l.description.Load().(string)
+    return description, ok
+}
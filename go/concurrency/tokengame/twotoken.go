@@ -0,0 +1,310 @@
+package main
+
+import (
+    "context"
+    "errors"
+    "flag"
+    "fmt"
+    "os"
+    "os/signal"
+    "sync"
+    "sync/atomic"
+    "time"
+)
+
+// ErrDeadlock is returned by StartTwoToken when the tokens stopped moving
+var ErrDeadlock = errors.New("deadlock")
+
+// the directions the two tokens of StartTwoToken travel the ring in
+const (
+    clockwise = "clockwise"
+    counterclockwise = "counterclockwise"
+)
+
+// StartTwoToken plays with two tokens going around a ring of n players in
+// opposite directions, the clockwise one starting at the first player and the
+// other one half way around, until each made laps laps. A player holds a token
+// for the configured delay, then passes it on to its neighbour in the direction
+// of the token.
+//
+// The naive players block on that send, so when the tokens meet, two neighbours
+// end up sending to each other and neither is receiving: the game deadlocks. The
+// safe players keep receiving while they wait to send, so they always take the
+// token coming their way. A watchdog ends the game with ErrDeadlock once no token
+// moved for config.StallTimeout
+func StartTwoToken(ctx context.Context, config Config, n, laps int, safe bool) error {
+
+    if n < 2 {
+        return fmt.Errorf("at least two players are needed, got %d", n)
+    }
+
+    if laps < 1 {
+        return fmt.Errorf("at least one lap is needed, got %d", laps)
+    }
+
+    if config.StallTimeout <= 0 {
+        return fmt.Errorf("the watchdog needs a stall timeout, got %s", config.StallTimeout)
+    }
+
+    ctx, cancel := context.WithCancel(ctx)
+    defer cancel()
+
+    //
+    // right[i] carries the clockwise token from player i to player i + 1, and
+    // left[i] the counterclockwise one from player i to player i - 1
+    //
+    right := make([]chan Token[string], n)
+    left := make([]chan Token[string], n)
+
+    for i := range right {
+        right[i] = make(chan Token[string])
+        left[i] = make(chan Token[string])
+    }
+
+    //
+    // a token that made its laps is retired by the player receiving it, and the
+    // game is over once both are
+    //
+    hops := laps * n
+    retired := make(chan string, 2)
+
+    //
+    // every receive counts as progress, the watchdog looks for none
+    //
+    var moves atomic.Int64
+
+    var waitGroup sync.WaitGroup
+
+    for i := 0; i < n; i++ {
+
+        twoTokenPlayer := naiveTwoTokenPlayer
+
+        if safe {
+            twoTokenPlayer = safeTwoTokenPlayer
+        }
+
+        var holding []Token[string]
+
+        if i == 0 {
+            holding = append(holding, Token[string]{Value: clockwise})
+        }
+
+        if i == n / 2 {
+            holding = append(holding, Token[string]{Value: counterclockwise})
+        }
+
+        p := twoTokenRing{
+            name: playerName(i),
+            clockwiseIn: right[(i + n - 1) % n],
+            clockwiseOut: right[i],
+            counterclockwiseIn: left[(i + 1) % n],
+            counterclockwiseOut: left[i],
+            hops: hops,
+            retired: retired,
+            moves: &moves,
+        }
+
+        waitGroup.Add(1)
+
+        go func() {
+            defer waitGroup.Done()
+            twoTokenPlayer(ctx, config, p, holding)
+        }()
+    }
+
+    watchdog := time.NewTicker(config.StallTimeout)
+    defer watchdog.Stop()
+
+    var err error
+    last := moves.Load()
+
+    for done := 0; done < 2 && err == nil; {
+
+        select {
+        case direction := <-retired:
+            config.logf("game", "the %s token made its %d laps", direction, laps)
+            done ++
+
+        case <-watchdog.C:
+
+            if moved := moves.Load(); moved != last {
+                last = moved
+                break
+            }
+
+            err = fmt.Errorf("%w: no token moved for %s, after %d moves", ErrDeadlock, config.StallTimeout, last)
+            config.logf("watchdog", "%v", err)
+
+        case <-ctx.Done():
+            err = ctx.Err()
+        }
+    }
+
+    cancel()
+    waitGroup.Wait()
+
+    return err
+}
+
+// twoTokenRing is what a player of StartTwoToken is connected to
+type twoTokenRing struct {
+    name string
+    clockwiseIn, counterclockwiseIn <-chan Token[string]
+    clockwiseOut, counterclockwiseOut chan<- Token[string]
+    hops int
+    retired chan<- string
+    moves *atomic.Int64
+}
+
+// outFor returns the channel the token goes on, depending on its direction
+func (r twoTokenRing) outFor(token Token[string]) chan<- Token[string] {
+
+    if token.Value == clockwise {
+        return r.clockwiseOut
+    }
+
+    return r.counterclockwiseOut
+}
+
+// arrived takes a received token: it returns false if the token made its laps and
+// was retired, and true if it must be passed on
+func (r twoTokenRing) arrived(config Config, token Token[string]) bool {
+
+    r.moves.Add(1)
+
+    if token.Hops == r.hops {
+        config.logf(r.name, "retiring the %s token, hop %d", token.Value, token.Hops)
+        r.retired <- token.Value
+        return false
+    }
+
+    config.logf(r.name, "got the %s token, hop %d", token.Value, token.Hops)
+    return true
+}
+
+// naiveTwoTokenPlayer passes on every token it gets before receiving again, and
+// so cannot take a token while its own send is blocked
+func naiveTwoTokenPlayer(ctx context.Context, config Config, r twoTokenRing, holding []Token[string]) {
+
+    for {
+
+        if len(holding) == 0 {
+
+            select {
+            case token := <-r.clockwiseIn:
+                holding = append(holding, token)
+            case token := <-r.counterclockwiseIn:
+                holding = append(holding, token)
+            case <-ctx.Done():
+                return
+            }
+
+            if !r.arrived(config, holding[0]) {
+                holding = holding[:0]
+                continue
+            }
+        }
+
+        token := holding[0]
+        holding = holding[1:]
+
+        if !sleepCtx(ctx, config.Delay) {
+            config.logf(r.name, "shutting down, still holding the %s token", token.Value)
+            return
+        }
+
+        token.Hops ++
+
+        select {
+        case r.outFor(token) <- token:
+            config.Metrics.handoff()
+        case <-ctx.Done():
+            config.logf(r.name, "shutting down, still sending the %s token", token.Value)
+            return
+        }
+    }
+}
+
+// safeTwoTokenPlayer waits in a single select to send the tokens it holds and to
+// receive, so two neighbours sending to each other always find a receiver. It
+// holds at most one token of each direction, since there is only one of each
+func safeTwoTokenPlayer(ctx context.Context, config Config, r twoTokenRing, holding []Token[string]) {
+
+    var clockwiseToken, counterclockwiseToken Token[string]
+    var clockwiseOut, counterclockwiseOut chan<- Token[string]
+
+    //
+    // hold puts a token aside for sending, the nil channels keep the send cases of
+    // the directions without a token from ever firing
+    //
+    hold := func(token Token[string]) {
+
+        //
+        // a cancelled sleep is seen by the select right after
+        //
+        sleepCtx(ctx, config.Delay)
+        token.Hops ++
+
+        if token.Value == clockwise {
+            clockwiseToken, clockwiseOut = token, r.clockwiseOut
+        } else {
+            counterclockwiseToken, counterclockwiseOut = token, r.counterclockwiseOut
+        }
+    }
+
+    for _, token := range holding {
+        hold(token)
+    }
+
+    for {
+
+        select {
+        case clockwiseOut <- clockwiseToken:
+            clockwiseOut = nil
+            config.Metrics.handoff()
+
+        case counterclockwiseOut <- counterclockwiseToken:
+            counterclockwiseOut = nil
+            config.Metrics.handoff()
+
+        case token := <-r.clockwiseIn:
+
+            if r.arrived(config, token) {
+                hold(token)
+            }
+
+        case token := <-r.counterclockwiseIn:
+
+            if r.arrived(config, token) {
+                hold(token)
+            }
+
+        case <-ctx.Done():
+            return
+        }
+    }
+}
+
+func runTwoToken(args []string) int {
+
+    flags := flag.NewFlagSet("twotoken", flag.ExitOnError)
+    delay := flags.Duration("delay", 200 * time.Millisecond, "how long a player holds a token before passing it on")
+    n := flags.Int("n", 4, "the number of players in the ring")
+    laps := flags.Int("laps", 3, "how many laps each token makes")
+    safe := flags.Bool("safe", false, "keep receiving while waiting to send, which avoids the deadlock")
+    watchdog := flags.Duration("watchdog", time.Second, "how long the tokens may stand still before the game is declared deadlocked")
+    flags.Parse(args)
+
+    ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+    defer stop()
+
+    logger := NewLogger(os.Stdout)
+    defer logger.Close()
+
+    if err := StartTwoToken(ctx, Config{Delay: *delay, StallTimeout: *watchdog, Logger: logger}, *n, *laps, *safe); err != nil {
+        fmt.Fprintln(os.Stderr, err)
+        return 1
+    }
+
+    return 0
+}
@@ -0,0 +1,21 @@
+package main
+
+import (
+    "context"
+    "errors"
+    "testing"
+    "time"
+)
+
+func TestSafePlayersFinishWhereNaiveOnesDeadlock(t *testing.T) {
+
+    config := Config{StallTimeout: 100 * time.Millisecond}
+
+    if err := StartTwoToken(context.Background(), config, 4, 3, true); err != nil {
+        t.Fatalf("the safe players did not finish their laps: %v", err)
+    }
+
+    if err := StartTwoToken(context.Background(), config, 4, 3, false); !errors.Is(err, ErrDeadlock) {
+        t.Fatalf("the naive players got %v, want %v", err, ErrDeadlock)
+    }
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+    "fmt"
+    "io"
+    "strings"
+)
+
+// ringRenderer draws the ring on a single line, the player holding the token in
+// brackets and a token in flight as an arrow to the next player:
+//
+//     A --.--> B    C
+//
+// The frames all have the same width, so each one fully covers the previous one
+type ringRenderer struct {
+    names []string
+    seats map[string]int
+    arrow string
+    gap string
+}
+
+func newRingRenderer(names []string, value string) *ringRenderer {
+
+    r := &ringRenderer{names: names, seats: make(map[string]int), arrow: "--" + value + "-->"}
+    r.gap = strings.Repeat(" ", len(r.arrow))
+
+    for i, name := range names {
+        r.seats[name] = i
+    }
+
+    return r
+}
+
+// frame draws the ring as the event leaves it: after a Receive the receiver has
+// the token, after a Send it is on its way from the sender to the next player.
+// The arrow from the last player wraps around to the first
+func (r *ringRenderer) frame(e Event) string {
+
+    seat := r.seats[e.Player]
+    var b strings.Builder
+
+    for i, name := range r.names {
+
+        if e.Action == Receive && i == seat {
+            fmt.Fprintf(&b, "[%s]", name)
+        } else {
+            fmt.Fprintf(&b, " %s ", name)
+        }
+
+        if e.Action == Send && i == seat {
+            b.WriteString(r.arrow)
+        } else {
+            b.WriteString(r.gap)
+        }
+    }
+
+    return b.String()
+}
+
+// render redraws the ring in place for every event, with a carriage return, until
+// the events channel is closed, and then ends the line
+func render(w io.Writer, r *ringRenderer, events <-chan Event) {
+
+    for e := range events {
+        fmt.Fprintf(w, "\r%s hop %d", r.frame(e), e.Hop)
+    }
+
+    fmt.Fprintln(w)
+}
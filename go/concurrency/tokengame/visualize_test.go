@@ -0,0 +1,49 @@
+package main
+
+import (
+    "bytes"
+    "testing"
+)
+
+func TestRingRendererFrames(t *testing.T) {
+
+    r := newRingRenderer([]string{"A", "B", "C"}, ".")
+    gap := "      "
+
+    frames := []struct {
+        event Event
+        want string
+    }{
+        {Event{Player: "A", Action: Send, Hop: 1}, " A --.--> B " + gap + " C " + gap},
+        {Event{Player: "B", Action: Receive, Hop: 1}, " A " + gap + "[B]" + gap + " C " + gap},
+        {Event{Player: "C", Action: Send, Hop: 3}, " A " + gap + " B " + gap + " C --.-->"},
+    }
+
+    for _, f := range frames {
+
+        if got := r.frame(f.event); got != f.want {
+            t.Errorf("%s %s hop %d is drawn %q, want %q", f.event.Player, f.event.Action, f.event.Hop, got, f.want)
+        }
+    }
+
+    //
+    // render redraws every frame over the previous one, and ends the line once
+    // the events are over
+    //
+    events := make(chan Event, len(frames))
+
+    for _, f := range frames {
+        events <- f.event
+    }
+
+    close(events)
+
+    var output bytes.Buffer
+    render(&output, r, events)
+
+    want := "\r" + frames[0].want + " hop 1\r" + frames[1].want + " hop 1\r" + frames[2].want + " hop 3\n"
+
+    if output.String() != want {
+        t.Fatalf("render wrote %q, want %q", output.String(), want)
+    }
+}